@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/anrid/traderbot/pkg/coingecko"
+	"github.com/anrid/traderbot/pkg/jsoncache"
+	"github.com/spf13/pflag"
+)
+
+const cacheUsage = `Usage: cli cache <command> [flags]
+
+Commands:
+  list   List cached entries with key, source, size, age and expiry
+  show   Print the contents of a cached entry, e.g. cli cache show coingecko/bitcoin-365-days-usd
+  clear  Remove cached entries by key pattern or source
+  warm   Download and cache market charts for a list of coin IDs
+`
+
+func runCache(args []string) {
+	if len(args) == 0 {
+		fmt.Print(cacheUsage)
+		os.Exit(-1)
+	}
+
+	switch args[0] {
+	case "list":
+		cacheList(args[1:])
+	case "show":
+		cacheShow(args[1:])
+	case "clear":
+		cacheClear(args[1:])
+	case "warm":
+		cacheWarm(args[1:])
+	default:
+		fmt.Print(cacheUsage)
+		os.Exit(-1)
+	}
+}
+
+func cacheList(args []string) {
+	fs := pflag.NewFlagSet("cache list", pflag.ExitOnError)
+	source := fs.StringP("source", "s", "", "Only list entries from this source, e.g. coingecko or messari")
+	expired := fs.Bool("expired", false, "Only list expired entries")
+	fs.Parse(args)
+
+	es, err := jsoncache.List()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tSOURCE\tSIZE\tAGE\tEXPIRES")
+
+	var count int
+	for _, e := range es {
+		if *source != "" && e.Source != *source {
+			continue
+		}
+		if *expired && !e.Expired() {
+			continue
+		}
+		count++
+
+		expires := "expired"
+		if !e.Expired() {
+			expires = "in " + formatDuration(time.Until(e.ExpiresAt))
+		}
+
+		size := formatBytes(e.Size)
+		if e.Compressed {
+			size += " (gz)"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s (%s)\n", e.Key, e.Source, size, formatDuration(e.Age()), expires, e.Period)
+	}
+	w.Flush()
+
+	fmt.Printf("\n%d entries in %s\n", count, jsoncache.Dir)
+}
+
+func cacheShow(args []string) {
+	fs := pflag.NewFlagSet("cache show", pflag.ExitOnError)
+	raw := fs.Bool("raw", false, "Print JSON as stored instead of indenting it")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		log.Fatal("expected exactly one key, e.g. cli cache show coingecko/bitcoin-365-days-usd")
+	}
+	key := fs.Arg(0)
+
+	es, err := jsoncache.List()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Show the most recent entry for the key; List sorts by creation
+	// time within a key.
+	var found *jsoncache.Entry
+	for _, e := range es {
+		if e.Key == key || e.File == key {
+			found = e
+		}
+	}
+	if found == nil {
+		log.Fatalf("no cached entry found for key `%s`", key)
+	}
+
+	data, err := jsoncache.ReadRaw(found)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if !*raw {
+		var v interface{}
+		err = json.Unmarshal(data, &v)
+		if err != nil {
+			log.Fatal(err)
+		}
+		data, err = json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	fmt.Println(string(data))
+}
+
+func cacheClear(args []string) {
+	fs := pflag.NewFlagSet("cache clear", pflag.ExitOnError)
+	pattern := fs.StringP("pattern", "p", "", "Remove entries with keys matching this glob pattern, e.g. 'coingecko/terra-*'")
+	source := fs.StringP("source", "s", "", "Remove entries from this source, e.g. coingecko or messari")
+	expired := fs.Bool("expired", false, "Remove expired entries")
+	all := fs.Bool("all", false, "Remove all entries")
+	fs.Parse(args)
+
+	if *pattern == "" && *source == "" && !*expired && !*all {
+		fs.PrintDefaults()
+		log.Fatal("one of --pattern, --source, --expired or --all is required")
+	}
+
+	if *pattern != "" {
+		// Catch malformed patterns up front.
+		if _, err := matchKey(*pattern, ""); err != nil {
+			log.Fatalf("invalid pattern `%s`: %s", *pattern, err)
+		}
+	}
+
+	removed, err := jsoncache.Clear(func(e *jsoncache.Entry) bool {
+		if *all {
+			return true
+		}
+		if *pattern != "" {
+			if ok, _ := matchKey(*pattern, e.Key); !ok {
+				return false
+			}
+		}
+		if *source != "" && e.Source != *source {
+			return false
+		}
+		if *expired && !e.Expired() {
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, e := range removed {
+		fmt.Printf("Removed %s (%s)\n", e.Key, e.File)
+	}
+	fmt.Printf("\nRemoved %d entries\n", len(removed))
+}
+
+func cacheWarm(args []string) {
+	fs := pflag.NewFlagSet("cache warm", pflag.ExitOnError)
+	ids := fs.StringSliceP("ids", "i", nil, "Coin IDs to download market charts for (required)")
+	periodInDays := fs.UintP("days", "d", 365, "Number of days of market data to download (default: 365)")
+	compress := fs.Bool("compress", false, "Store cached data gzip compressed")
+	fs.Parse(args)
+
+	if len(*ids) == 0 {
+		fs.PrintDefaults()
+		log.Fatal("--ids or -i flag required but missing")
+	}
+
+	jsoncache.Compress = *compress
+
+	cg := coingecko.New(coingecko.USD)
+
+	for _, id := range *ids {
+		m, err := cg.MarketChartWithCache(id, *periodInDays, jsoncache.InvalidateDaily)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Cached %s (%s) : %d days of prices\n", m.Name, strings.ToUpper(m.Symbol), len(m.Prices))
+	}
+}
+
+// matchKey is like path.Match but lets `*` match across slashes, so that
+// e.g. `coingecko/*` matches every CoinGecko key.
+func matchKey(pattern, key string) (bool, error) {
+	return path.Match(strings.ReplaceAll(pattern, "/", ":"), strings.ReplaceAll(key, "/", ":"))
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return fmt.Sprintf("%dd", int(d.Hours()/24))
+}
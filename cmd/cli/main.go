@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"log"
+	"os"
 	"strings"
 
 	"github.com/anrid/traderbot/pkg/coingecko"
@@ -12,16 +13,56 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "cache":
+			runCache(os.Args[2:])
+			return
+		case "screener":
+			runScreener(os.Args[2:])
+			return
+		}
+	}
+
 	listOnly := pflag.BoolP("list", "l", false, "List top 100 markets (coins) on CoinGecko")
-	useEMS921 := pflag.BoolP("ems921", "9", true, "Use '9-Day/21-Day EMS CrossOver' strategy (default: true)")
+	useEMS921 := pflag.BoolP("ems921", "9", true, "Use 'Short/Long CrossOver' strategy, by default with 9-Day and 21-Day EMAs (default: true)")
+	shortSpec := pflag.String("short", "ema(9)", "Short indicator for the crossover strategy, see --indicators (default: ema(9))")
+	longSpec := pflag.String("long", "ema(21)", "Long indicator for the crossover strategy, see --indicators (default: ema(21))")
+	listIndicators := pflag.Bool("indicators", false, "List indicators that can be used with --short and --long")
 	ids := pflag.StringSliceP("ids", "i",
 		[]string{"terra-luna", "solana", "bitcoin", "ethereum"},
 		"Coin IDs to trade (default: [\"terra-luna\", \"solana\", \"bitcoin\", \"ethereum\"]",
 	)
 	periodInDays := pflag.UintP("days", "d", 365, "Start trading X number of days ago (default: 365)")
+	compareBTC := pflag.Bool("btc", false, "Also compare strategies with buying and holding bitcoin")
+	chartPath := pflag.String("chart", "", "Write equity curve charts of strategies and benchmarks to this dir")
+	feePct := pflag.Float64("fee", 0.0, "Trading fee as a percentage of the amount traded, e.g. 0.1 for 0.1% (default: 0.0)")
+	fixedFee := pflag.Float64("fixed-fee", 0.0, "Trading fee in USD per trade (default: 0.0)")
+	spreadPct := pflag.Float64("spread", 0.0, "Bid/ask spread as a percentage, half of which is paid on every trade (default: 0.0)")
+	slippagePct := pflag.Float64("slippage", 0.0, "Slippage as a percentage per 1% of the day's total volume traded (default: 0.0)")
+	stopLossPct := pflag.Float64("stop-loss", 0.0, "Sell when the price drops this percentage below the entry price (default: disabled)")
+	stopLossATR := pflag.Float64("stop-loss-atr", 0.0, "Sell when the price drops this multiple of the 14-day ATR below the entry price (default: disabled)")
+	takeProfitPct := pflag.Float64("take-profit", 0.0, "Sell when the price rises this percentage above the entry price (default: disabled)")
+	trailingStopPct := pflag.Float64("trailing-stop", 0.0, "Sell when the price drops this percentage below its highest since entry (default: disabled)")
+	maxDays := pflag.Int("max-days", 0, "Sell after holding a position this many days (default: disabled)")
 
 	pflag.Parse()
 
+	if *listIndicators {
+		fmt.Printf("Indicators:\n%s\n", trade.IndicatorUsage())
+		return
+	}
+
+	// Validate specs before fetching any data.
+	short, err := trade.ParseSpec(*shortSpec)
+	if err != nil {
+		log.Fatalf("invalid --short indicator `%s`: %s", *shortSpec, err)
+	}
+	long, err := trade.ParseSpec(*longSpec)
+	if err != nil {
+		log.Fatalf("invalid --long indicator `%s`: %s", *longSpec, err)
+	}
+
 	cg := coingecko.New(coingecko.USD)
 
 	if *listOnly {
@@ -37,24 +78,89 @@ func main() {
 		return
 	}
 
+	costs := &trade.TradingCosts{
+		FeePct:      *feePct,
+		FixedFee:    *fixedFee,
+		SpreadPct:   *spreadPct,
+		SlippagePct: *slippagePct,
+	}
+
+	var btc *coingecko.Market
+	if *compareBTC {
+		btc, err = cg.MarketChartWithCache("bitcoin", *periodInDays, jsoncache.InvalidateDaily)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	for _, id := range *ids {
 		m, err := cg.MarketChartWithCache(id, *periodInDays, jsoncache.InvalidateDaily)
 		if err != nil {
 			log.Fatal(err)
 		}
+		if *periodInDays <= coingecko.MaxIntradayOHLCDays {
+			// Candle-based indicators are only close-to-close without them.
+			m.OHLC, err = cg.OHLCWithCache(id, *periodInDays, jsoncache.InvalidateDaily)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
 
 		if *useEMS921 {
-			ema9d := trade.NewEMAIndicator(9, m.Prices)
-			ema21d := trade.NewEMAIndicator(21, m.Prices)
+			shortIn, err := short.New(m)
+			if err != nil {
+				log.Fatal(err)
+			}
+			longIn, err := long.New(m)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			var s trade.Strategy = trade.NewEMACrossOverStrategy(shortIn, longIn)
+
+			exits := trade.Exits{
+				StopLossPct:     *stopLossPct,
+				StopLossATR:     *stopLossATR,
+				TakeProfitPct:   *takeProfitPct,
+				TrailingStopPct: *trailingStopPct,
+				MaxDays:         *maxDays,
+			}
+			if exits.Enabled() {
+				if exits.StopLossATR > 0 {
+					exits.ATR, err = trade.NewATRIndicator(14, m.Candles())
+					if err != nil {
+						log.Fatal(err)
+					}
+				}
 
-			s, err := trade.NewEMACrossOverStrategy(ema9d, ema21d, m, m)
+				s, err = trade.NewExitStrategy(s, exits, m)
+				if err != nil {
+					log.Fatal(err)
+				}
+			}
+
+			trades, err := trade.RunStrategy(s, m, m)
 			if err != nil {
 				log.Fatal(err)
 			}
 
 			initialInvestment := 10_000.0 // USD.
 
-			trade.ExecuteTradesAndPrint("9-Day/21-Day EMS CrossOver Strategy", initialInvestment, s.Trades)
+			res := trade.ExecuteTradesWithCosts(s.Name(), initialInvestment, m, trades, costs)
+			res.Print()
+			if len(res.Fills) > 0 {
+				if btc != nil && m.ID != btc.ID {
+					res.AddBenchmark(btc)
+				}
+				res.Metrics().Print()
+				res.PrintBenchmarks()
+
+				if *chartPath != "" {
+					if err := trade.RenderBacktestChart(*chartPath, res); err != nil {
+						log.Fatal(err)
+					}
+				}
+			}
 		}
 	}
 }
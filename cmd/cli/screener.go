@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/anrid/traderbot/pkg/jsoncache"
+	"github.com/anrid/traderbot/pkg/messari"
+	"github.com/spf13/pflag"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// screenerColumns are the columns printed for every asset, in order.
+var screenerColumns = []string{"price", "inflation", "y10", "s2f", "mcap", "ath_drawdown"}
+
+func runScreener(args []string) {
+	fs := pflag.NewFlagSet("screener", pflag.ExitOnError)
+	token := fs.StringP("token", "t", "", "Messari API token (required)")
+	filter := fs.StringP("filter", "f", "mcap > 0 && inflation > 0 || mcap > 0 && y10 > 0",
+		"Filter expression, e.g. 'inflation > 5 && mcap >= 100m || s2f > 50'")
+	sortBy := fs.StringP("sort", "s", "-mcap", "Comma separated fields to sort by, prefix with - for descending order")
+	format := fs.StringP("format", "o", "table", "Output format: table, csv or json")
+	limit := fs.IntP("limit", "n", 0, "Max number of assets to print (0 = no limit)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: cli screener [flags]\n\nFlags:\n%s\nFields:\n", fs.FlagUsages())
+
+		var names []string
+		for name := range messari.ScreenerFields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(os.Stderr, "  %-14s %s\n", name, messari.ScreenerFields[name])
+		}
+		fmt.Fprintf(os.Stderr, "  %-14s %s\n", "<path>", "Any Messari asset field, e.g. metrics.marketcap.rank")
+	}
+	fs.Parse(args)
+
+	if *token == "" {
+		fs.Usage()
+		log.Fatal("--token or -t flag required but missing")
+	}
+
+	f, err := messari.ParseFilter(*filter)
+	if err != nil {
+		log.Fatalf("invalid filter `%s`: %s", *filter, err)
+	}
+
+	keys, err := messari.ParseSort(*sortBy)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	m := messari.New(*token)
+
+	assets, err := m.AssetsWithCache(jsoncache.InvalidateWeekly)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	found := messari.Screen(assets, f, keys)
+	if *limit > 0 && len(found) > *limit {
+		found = found[:*limit]
+	}
+
+	switch *format {
+	case "table":
+		printScreenerTable(found)
+	case "csv":
+		printScreenerCSV(found)
+	case "json":
+		printScreenerJSON(found)
+	default:
+		log.Fatalf("unknown format `%s`, expected one of: table, csv, json", *format)
+	}
+}
+
+func printScreenerTable(as []*messari.Asset) {
+	pr := message.NewPrinter(language.English)
+
+	pr.Printf("%4s  %-8s %-30s  %12s  %10s  %10s  %10s  %18s  %12s\n",
+		"", "Symbol", "Name", "Price", "Inflation", "Y+10", "S2F", "Market Cap", "ATH Down")
+
+	cell := func(a *messari.Asset, field, format string) string {
+		v, found := messari.ScreenerValue(a, field)
+		if !found {
+			return "-"
+		}
+		return pr.Sprintf(format, v)
+	}
+
+	for i, a := range as {
+		pr.Printf("%4d. %-8s %-30s  %12s  %10s  %10s  %10s  %18s  %12s\n",
+			i+1, a.Symbol, a.Name,
+			cell(a, "price", "$%.03f"),
+			cell(a, "inflation", "%.02f%%"),
+			cell(a, "y10", "%.02f%%"),
+			cell(a, "s2f", "%.02f"),
+			cell(a, "mcap", "$%.f"),
+			cell(a, "ath_drawdown", "%.02f%%"),
+		)
+	}
+	pr.Printf("\n%d assets\n", len(as))
+}
+
+func printScreenerCSV(as []*messari.Asset) {
+	w := csv.NewWriter(os.Stdout)
+
+	w.Write(append([]string{"symbol", "name", "slug"}, screenerColumns...))
+	for _, a := range as {
+		row := []string{a.Symbol, a.Name, a.Slug}
+		for _, c := range screenerColumns {
+			var cell string
+			if v, found := messari.ScreenerValue(a, c); found {
+				cell = strconv.FormatFloat(v, 'f', -1, 64)
+			}
+			row = append(row, cell)
+		}
+		w.Write(row)
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func printScreenerJSON(as []*messari.Asset) {
+	out := make([]map[string]interface{}, 0, len(as))
+	for _, a := range as {
+		row := map[string]interface{}{
+			"symbol": a.Symbol,
+			"name":   a.Name,
+			"slug":   a.Slug,
+		}
+		for _, c := range screenerColumns {
+			if v, found := messari.ScreenerValue(a, c); found {
+				row[c] = v
+			} else {
+				row[c] = nil
+			}
+		}
+		out = append(out, row)
+	}
+
+	b, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(strings.TrimSpace(string(b)))
+}
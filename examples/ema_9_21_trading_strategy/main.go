@@ -22,6 +22,8 @@ func main() {
 	initialInvestment := pflag.Float64("invest", 10_000.00, "Initial investment (default: 10,000.00 USD)")
 	startDaysAgo := pflag.UintP("start", "d", 365, "Start our trading strategy 365 days ago counting from today (default: 365)")
 	startDate := pflag.String("date", "", "Start our trading strategy from this date, format YYYY-MM-DD")
+	shortSpec := pflag.String("short", "ema(9)", "Short indicator, e.g. sma(20) or hma(9) (default: ema(9))")
+	longSpec := pflag.String("long", "ema(21)", "Long indicator, e.g. sma(50) or hma(21) (default: ema(21))")
 
 	pflag.Parse()
 
@@ -48,13 +50,21 @@ func main() {
 		log.Fatal(err)
 	}
 
-	ema9d := trade.NewEMAIndicator(9, tracking.Prices)
-	ema21d := trade.NewEMAIndicator(21, tracking.Prices)
+	short, err := trade.NewIndicatorFromSpec(*shortSpec, tracking)
+	if err != nil {
+		log.Fatal(err)
+	}
+	long, err := trade.NewIndicatorFromSpec(*longSpec, tracking)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	s := trade.NewEMACrossOverStrategy(short, long)
 
-	s, err := trade.NewEMACrossOverStrategy(ema9d, ema21d, tracking, trading)
+	trades, err := trade.RunStrategy(s, tracking, trading)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	trade.ExecuteTradesAndPrint("9-Day/21-Day EMS CrossOver Strategy", *initialInvestment, s.Trades)
+	trade.ExecuteTradesAndPrint(s.Name(), *initialInvestment, trades)
 }
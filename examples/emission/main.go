@@ -0,0 +1,89 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/anrid/traderbot/pkg/coingecko"
+	"github.com/anrid/traderbot/pkg/messari"
+	"github.com/anrid/traderbot/pkg/trade"
+	"github.com/spf13/pflag"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+func main() {
+	token := pflag.StringP("token", "t", "", "Messari API token (required)")
+	assetKey := pflag.StringP("asset", "a", "osmosis", "Messari asset slug or symbol (default: osmosis)")
+	days := pflag.IntP("days", "d", 365, "Number of days to project (default: 365)")
+	apr := pflag.Float64("apr", 100.0, "APR of a farm paying rewards in the token (default: 100.0)")
+
+	pflag.Parse()
+
+	if *token == "" {
+		pflag.PrintDefaults()
+		log.Fatal("--token or -t flag required but missing")
+	}
+
+	m := messari.New(*token)
+
+	am, err := m.AssetMetrics(*assetKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	a := &messari.Asset{ID: am.ID, Symbol: am.Symbol, Name: am.Name, Slug: am.Slug, Metrics: am.Metrics}
+
+	model, err := trade.NewEmissionModel(a, time.Now().UTC())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	pr := message.NewPrinter(language.English)
+
+	pr.Printf("\n%s (%s) supply projection\n", a.Name, strings.ToUpper(a.Symbol))
+	pr.Printf("=============================================================\n\n")
+	pr.Printf("- Circulating supply : %.f\n", model.Circulating)
+	pr.Printf("- Annual inflation   : %.02f %%\n", model.AnnualInflationPct)
+	pr.Printf("- Y+10 supply        : %.f\n", model.YPlus10)
+	pr.Printf("- Y2050 supply       : %.f\n\n", model.Y2050)
+
+	for _, years := range []float64{0.25, 0.5, 1, 2, 5, 10} {
+		pr.Printf("  +%5.02f years : supply %18.f  (price factor at constant market cap: %.04f)\n",
+			years, model.SupplyAt(years), model.Circulating/model.SupplyAt(years))
+	}
+
+	// Farm the token against a stablecoin, with and without dilution.
+	fc := trade.NewForecast(coingecko.USD, 10_000.0, *days)
+
+	price := am.MarketData.PriceUSD
+	if price == 0 {
+		price = 1.0
+	}
+
+	stable := fc.CreateMarket("USD Coin", "USDC", 1.0, nil)
+	flat := fc.CreateMarket(a.Name, a.Symbol, price, nil)
+	diluted := fc.CreateMarket(a.Name, a.Symbol, price, nil, model)
+
+	for _, tok := range []*coingecko.Market{flat, diluted} {
+		err = fc.AddLPFarm(tok, stable, *apr, 0.0, 0.0)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	last := func(f *trade.LPFarm) float64 {
+		h := f.GetChangeHistoryAsc()
+		return h[len(h)-1].TotalValue
+	}
+
+	withoutDilution := last(fc.Farms[0])
+	withDilution := last(fc.Farms[1])
+
+	pr.Printf("\nFarming %s/USDC at %.f%% APR for %d days:\n\n", strings.ToUpper(a.Symbol), *apr, *days)
+	pr.Printf("- Dilution            : %.02f %%\n", model.DilutionPct(*days))
+	pr.Printf("- Without emissions   : %.02f\n", withoutDilution)
+	pr.Printf("- With emissions      : %.02f\n", withDilution)
+	pr.Printf("- Lost to emissions   : %.02f (%.02f %%)\n\n", withoutDilution-withDilution, (1-withDilution/withoutDilution)*100)
+}
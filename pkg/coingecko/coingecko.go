@@ -54,33 +54,26 @@ func (cg *CoinGecko) Ping() bool {
 }
 
 func (cg *CoinGecko) MarketChartWithCache(coinID string, days uint, i jsoncache.InvalidateCachePeriod) (*Market, error) {
-	key := fmt.Sprintf("%s-%03d-days-%s", coinID, days, cg.Currency)
+	key := fmt.Sprintf("coingecko/%s-%03d-days-%s", coinID, days, cg.Currency)
 
-	c := new(Market)
-	err := jsoncache.Get(key, c, i)
+	var c *Market
+	hit, err := jsoncache.GetOrFetch(key, i, &c, func() (interface{}, error) {
+		return cg.MarketChart(coinID, days)
+	})
 	if err != nil {
-		if err != jsoncache.ErrNotFound {
-			return nil, err
-		}
-
-		// Perform call.
-		c, err = cg.MarketChart(coinID, days)
-		if err != nil {
-			return nil, err
-		}
+		return nil, err
+	}
+	printCacheUse(key, hit)
 
-		// Cache result.
-		err = jsoncache.Set(key, c, i)
-		if err != nil {
-			return nil, err
-		}
-		fmt.Printf("Downloaded data   : %s\n", key)
+	return c, nil
+}
 
-	} else {
+func printCacheUse(key string, hit bool) {
+	if hit {
 		fmt.Printf("Using cached data : %s\n", key)
+	} else {
+		fmt.Printf("Downloaded data   : %s\n", key)
 	}
-
-	return c, nil
 }
 
 func (cg *CoinGecko) Markets(ids ...string) ([]*Market, error) {
@@ -157,6 +150,51 @@ func (cg *CoinGecko) MarketChart(coinID string, days uint) (*Market, error) {
 	return c, nil
 }
 
+// MaxIntradayOHLCDays is the longest period OHLC returns intraday candles
+// for.
+const MaxIntradayOHLCDays = 30
+
+// OHLC returns price candles for a coin. CoinGecko picks the candle size
+// from the number of days: 30 minutes for 1 - 2 days, 4 hours for 3 - 30
+// days and 4 days beyond that. Intraday candles can be merged into daily
+// ones with Candles.Daily or Market.Candles, 4-day candles can't.
+func (cg *CoinGecko) OHLC(coinID string, days uint) (timeseries.Candles, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	u := url.URL{}
+	q := u.Query()
+	q.Add("vs_currency", string(cg.Currency))
+	q.Add("days", fmt.Sprintf("%d", days))
+	u.RawQuery = q.Encode()
+
+	url := "/coins/" + coinID + "/ohlc?" + u.RawQuery
+	// https://api.coingecko.com/api/v3/coins/bitcoin/ohlc?vs_currency=usd&days=30
+
+	var resp [][]float64
+
+	err := cg.pingAndGetJSON(ctx, apiBaseURI+url, nil, &resp)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not fetch OHLC for coin `%s`", coinID)
+	}
+
+	return timeseries.FromOHLCTuples(resp), nil
+}
+
+func (cg *CoinGecko) OHLCWithCache(coinID string, days uint, i jsoncache.InvalidateCachePeriod) (cs timeseries.Candles, err error) {
+	key := fmt.Sprintf("coingecko/%s-%03d-days-%s-ohlc", coinID, days, cg.Currency)
+
+	hit, err := jsoncache.GetOrFetch(key, i, &cs, func() (interface{}, error) {
+		return cg.OHLC(coinID, days)
+	})
+	if err != nil {
+		return nil, err
+	}
+	printCacheUse(key, hit)
+
+	return cs, nil
+}
+
 func (cg *CoinGecko) pingAndGetJSON(ctx context.Context, url string, payload, response interface{}) error {
 	// Ping once if we don't already have a successful ping.
 	if !cg.hasSuccessfulPing {
@@ -10,10 +10,11 @@ const (
 )
 
 type Market struct {
-	Currency     Fiat              `json:"currency"`
-	Prices       timeseries.Series `json:"prices"`
-	MarketCaps   timeseries.Series `json:"market_caps"`
-	TotalVolumes timeseries.Series `json:"total_volumes"`
+	Currency     Fiat               `json:"currency"`
+	Prices       timeseries.Series  `json:"prices"`
+	MarketCaps   timeseries.Series  `json:"market_caps"`
+	TotalVolumes timeseries.Series  `json:"total_volumes"`
+	OHLC         timeseries.Candles `json:"ohlc,omitempty"` // Intraday candles, see CoinGecko.OHLC and Candles
 
 	ID                    string  `json:"id"`                               // "usd-coin"
 	Symbol                string  `json:"symbol"`                           // "usdc"
@@ -41,3 +42,13 @@ type Market struct {
 	ATLDate               string  `json:"atl_date"`                         // "2021-05-19T13:14:05.611Z"
 	LastUpdated           string  `json:"last_updated"`                     // "2022-02-26T05:01:38.509Z"
 }
+
+// Candles returns a candle for every price, merged from the OHLC candles
+// if the market has them and approximated from closing prices otherwise,
+// see timeseries.CandlesFromSeries.
+func (m *Market) Candles() timeseries.Candles {
+	if len(m.OHLC) == 0 {
+		return timeseries.CandlesFromSeries(m.Prices)
+	}
+	return m.OHLC.Resample(m.Prices)
+}
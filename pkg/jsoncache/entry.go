@@ -0,0 +1,199 @@
+package jsoncache
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	metaSuffix = ".meta"
+)
+
+// Entry describes a cached value. It's stored in a small sidecar file
+// next to the cached data so that entries can be listed without reading
+// (and decompressing) their payloads.
+type Entry struct {
+	Key        string                `json:"key"`    // Key as given to Set, e.g. "coingecko/terra-luna-365-days-usd"
+	Source     string                `json:"source"` // First part of a key in the form "<source>/<name>", e.g. "coingecko"
+	File       string                `json:"file"`   // Name of the cache file in Dir
+	Period     InvalidateCachePeriod `json:"period"`
+	Size       int64                 `json:"size"` // Size of the cache file in bytes
+	Compressed bool                  `json:"compressed"`
+	CreatedAt  time.Time             `json:"created_at"`
+	ExpiresAt  time.Time             `json:"expires_at"`
+}
+
+func newEntry(key, file string, i InvalidateCachePeriod) *Entry {
+	now := time.Now()
+
+	var source string
+	if parts := strings.SplitN(key, "/", 2); len(parts) == 2 {
+		source = parts[0]
+	}
+
+	return &Entry{
+		Key:       key,
+		Source:    source,
+		File:      file,
+		Period:    i,
+		CreatedAt: now,
+		ExpiresAt: expiresAt(now, i),
+	}
+}
+
+func (e *Entry) Age() time.Duration {
+	return time.Since(e.CreatedAt)
+}
+
+func (e *Entry) Expired() bool {
+	return !time.Now().Before(e.ExpiresAt)
+}
+
+// List returns all cache entries in Dir ordered by key and creation time.
+// Expired entries are included, see Entry.Expired.
+func List() ([]*Entry, error) {
+	paths, err := filepath.Glob(filepath.Join(Dir, "*"+metaSuffix))
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not list cache dir %s", Dir)
+	}
+
+	var es []*Entry
+	for _, p := range paths {
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// Removed while we were listing.
+				continue
+			}
+			return nil, errors.Wrapf(err, "could not read cache entry metadata %s", p)
+		}
+
+		e := new(Entry)
+		err = json.Unmarshal(b, e)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not unmarshal cache entry metadata %s", p)
+		}
+		es = append(es, e)
+	}
+
+	sort.SliceStable(es, func(i, j int) bool {
+		if es[i].Key != es[j].Key {
+			return es[i].Key < es[j].Key
+		}
+		return es[i].CreatedAt.Before(es[j].CreatedAt)
+	})
+
+	return es, nil
+}
+
+// ReadRaw returns the (decompressed) JSON stored for the given entry.
+func ReadRaw(e *Entry) (json.RawMessage, error) {
+	b, err := readFile(e.File)
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(b), nil
+}
+
+// Remove deletes the given entry and its metadata from the cache.
+func Remove(e *Entry) error {
+	path := filepath.Join(Dir, e.File)
+
+	unlock, err := lockFile(path, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if mem := memoryCache(); mem != nil {
+		mem.remove(e.File)
+	}
+
+	for _, p := range []string{path, path + metaSuffix} {
+		err = os.Remove(p)
+		if err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "could not remove cache file %s", p)
+		}
+	}
+
+	// Leave the lock file in place; removing it while another process is
+	// waiting on it would let two writers lock different files.
+	return nil
+}
+
+// Clear removes all entries for which match returns true and returns
+// the removed entries.
+func Clear(match func(*Entry) bool) (removed []*Entry, err error) {
+	es, err := List()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range es {
+		if !match(e) {
+			continue
+		}
+
+		err = Remove(e)
+		if err != nil {
+			return
+		}
+		removed = append(removed, e)
+	}
+
+	return
+}
+
+// expiresAt returns when an entry created at the given time stops being
+// returned by Get, i.e. when createKey starts returning a new key.
+func expiresAt(created time.Time, i InvalidateCachePeriod) time.Time {
+	local := created.Local()
+	nextMonth := time.Date(local.Year(), local.Month()+1, 1, 0, 0, 0, 0, time.Local)
+
+	switch i {
+	case InvalidateHourly:
+		return created.UTC().Truncate(time.Hour).Add(time.Hour)
+	case InvalidateDaily:
+		return time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, time.Local)
+	case InvalidateWeekly:
+		// Keys contain the ISO week (UTC) as well as the month (local
+		// time), whichever changes first.
+		utc := created.UTC()
+		daysUntilMonday := (8 - int(utc.Weekday())) % 7
+		if daysUntilMonday == 0 {
+			daysUntilMonday = 7
+		}
+		nextWeek := time.Date(utc.Year(), utc.Month(), utc.Day()+daysUntilMonday, 0, 0, 0, 0, time.UTC)
+		if nextMonth.Before(nextWeek) {
+			return nextMonth
+		}
+		return nextWeek
+	case InvalidateMonthly:
+		return nextMonth
+	}
+
+	return created
+}
+
+func (i InvalidateCachePeriod) String() string {
+	switch i {
+	case InvalidateHourly:
+		return "hourly"
+	case InvalidateDaily:
+		return "daily"
+	case InvalidateWeekly:
+		return "weekly"
+	case InvalidateMonthly:
+		return "monthly"
+	case InvalidateNow:
+		return "now"
+	}
+	return "unknown"
+}
@@ -1,11 +1,14 @@
 package jsoncache
 
 import (
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"strings"
 	"time"
@@ -17,6 +20,13 @@ type InvalidateCachePeriod int
 
 var (
 	ErrNotFound = os.ErrNotExist
+
+	// Dir is where cached JSON files, temp files and lock files are stored.
+	Dir = filepath.Join(os.TempDir(), "jsoncache")
+
+	// Compress makes Set gzip payloads before writing them to disk. Get
+	// reads both compressed and uncompressed files regardless.
+	Compress = false
 )
 
 const (
@@ -27,6 +37,11 @@ const (
 	InvalidateNow
 )
 
+const (
+	dirPerm  os.FileMode = 0755
+	filePerm os.FileMode = 0644
+)
+
 func Get(key string, into interface{}, i InvalidateCachePeriod) error {
 	k := createKey(key, i)
 	return readJSON(k, into)
@@ -34,49 +49,293 @@ func Get(key string, into interface{}, i InvalidateCachePeriod) error {
 
 func Set(key string, data interface{}, i InvalidateCachePeriod) error {
 	k := createKey(key, i)
-	return writeJSON(k, data)
+	return writeJSON(k, data, newEntry(key, k, i))
+}
+
+// Delete removes the cached value for key, if any.
+func Delete(key string, i InvalidateCachePeriod) error {
+	return Remove(&Entry{File: createKey(key, i)})
+}
+
+// GetOrFetch reads the cached value for key into `into`, which must be a
+// non-nil pointer. On a cache miss it calls fetch, caches the result and
+// reads it into `into`, so fetch must return a value that unmarshals into
+// it. Concurrent calls for the same key share a single fetch, but every
+// caller decodes its own copy of the value.
+func GetOrFetch(key string, i InvalidateCachePeriod, into interface{}, fetch func() (interface{}, error)) (hit bool, err error) {
+	dst := reflect.ValueOf(into)
+	if dst.Kind() != reflect.Ptr || dst.IsNil() {
+		return false, errors.Errorf("expected a non-nil pointer but got %T", into)
+	}
+
+	k := createKey(key, i)
+
+	v, err, _ := Do(k, func() (interface{}, error) {
+		// Share the JSON rather than a decoded value, which callers could
+		// modify or race on.
+		b, err := readBytes(k)
+		if err == nil {
+			return result{b, true}, nil
+		}
+		if err != ErrNotFound {
+			return nil, err
+		}
+
+		fetched, err := fetch()
+		if err != nil {
+			return nil, err
+		}
+
+		b, err = json.Marshal(fetched)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not marshal data")
+		}
+
+		err = writeBytes(k, b, newEntry(key, k, i))
+		if err != nil {
+			return nil, err
+		}
+
+		return result{b, false}, nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	res, ok := v.(result)
+	if !ok {
+		return false, errors.Errorf("unexpected result %T for %s", v, key)
+	}
+
+	err = json.Unmarshal(res.b, into)
+	if err != nil {
+		return false, errors.Wrapf(err, "could not unmarshal data for %s into %T", key, into)
+	}
+
+	return res.hit, nil
+}
+
+type result struct {
+	b   []byte
+	hit bool
 }
 
 func readJSON(key string, into interface{}) error {
-	path := filepath.Join(os.TempDir(), key)
+	b, err := readBytes(key)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(b, into)
+	if err != nil {
+		return errors.Wrap(err, "could not unmarshal data")
+	}
+
+	return nil
+}
+
+// readBytes returns the JSON cached for key, from memory if possible.
+func readBytes(key string) ([]byte, error) {
+	mem := memoryCache()
+	if mem != nil {
+		if b, ok := mem.get(key); ok {
+			return b, nil
+		}
+	}
+
+	b, err := readFile(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if mem != nil {
+		mem.add(key, b)
+	}
+
+	return b, nil
+}
+
+// readFile returns the decompressed contents of a cache file.
+func readFile(key string) ([]byte, error) {
+	path := filepath.Join(Dir, key)
 
 	_, err := os.Stat(path)
 	if err != nil {
 		if !os.IsNotExist(err) {
-			return errors.Wrapf(err, "could not stat temp file %s", path)
+			return nil, errors.Wrapf(err, "could not stat cache file %s", path)
 		}
-		return ErrNotFound
+		return nil, ErrNotFound
+	}
+
+	unlock, err := lockFile(path, false)
+	if err != nil {
+		return nil, err
 	}
+	defer unlock()
 
 	b, err := ioutil.ReadFile(path)
 	if err != nil {
-		return errors.Wrapf(err, "could not read JSON from temp file %s", path)
+		if os.IsNotExist(err) {
+			// Removed while we were waiting for the lock.
+			return nil, ErrNotFound
+		}
+		return nil, errors.Wrapf(err, "could not read JSON from cache file %s", path)
 	}
 
-	err = json.Unmarshal(b, into)
+	b, err = decompress(b)
 	if err != nil {
-		return errors.Wrap(err, "could not unmarshal data")
+		return nil, errors.Wrapf(err, "could not decompress cache file %s", path)
 	}
 
-	return nil
+	return b, nil
 }
 
-func writeJSON(key string, data interface{}) error {
+func writeJSON(key string, data interface{}, e *Entry) error {
 	b, err := json.Marshal(data)
 	if err != nil {
 		return errors.Wrap(err, "could not marshal data")
 	}
+	return writeBytes(key, b, e)
+}
+
+// writeBytes caches marshaled JSON for key.
+func writeBytes(key string, data []byte, e *Entry) error {
+	b := data
+
+	var err error
+	if Compress {
+		b, err = compress(b)
+		if err != nil {
+			return errors.Wrap(err, "could not compress data")
+		}
+	}
 
-	path := filepath.Join(os.TempDir(), key)
+	e.Size = int64(len(b))
+	e.Compressed = Compress
+
+	meta, err := json.Marshal(e)
+	if err != nil {
+		return errors.Wrap(err, "could not marshal cache entry metadata")
+	}
+
+	err = os.MkdirAll(Dir, dirPerm)
+	if err != nil {
+		return errors.Wrapf(err, "could not create cache dir %s", Dir)
+	}
+
+	path := filepath.Join(Dir, key)
+
+	unlock, err := lockFile(path, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = writeFileAtomic(path, b)
+	if err != nil {
+		return err
+	}
+
+	if mem := memoryCache(); mem != nil {
+		mem.add(key, data)
+	}
+
+	return writeFileAtomic(path+metaSuffix, meta)
+}
+
+// writeFileAtomic writes to a temp file in the same dir and renames it
+// into place, so that readers never see a partially written file.
+func writeFileAtomic(path string, b []byte) error {
+	dir, name := filepath.Split(path)
+
+	tmp, err := ioutil.TempFile(dir, "."+name+".tmp-*")
+	if err != nil {
+		return errors.Wrapf(err, "could not create temp file for %s", path)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(b)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err == nil {
+		err = tmp.Chmod(filePerm)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrapf(err, "could not write to temp file %s", tmp.Name())
+	}
 
-	err = ioutil.WriteFile(path, b, os.ModePerm)
+	err = os.Rename(tmp.Name(), path)
 	if err != nil {
-		return errors.Wrapf(err, "could not write JSON to temp file %s", path)
+		return errors.Wrapf(err, "could not move temp file into place at %s", path)
 	}
 
 	return nil
 }
 
+func compress(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write(b)
+	if err != nil {
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// decompress gunzips b if it starts with the gzip magic number and
+// returns it untouched otherwise. A JSON document can never start with
+// these bytes.
+func decompress(b []byte) ([]byte, error) {
+	if len(b) < 2 || b[0] != 0x1f || b[1] != 0x8b {
+		return b, nil
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return ioutil.ReadAll(r)
+}
+
+// lockFile takes an advisory lock on a lock file next to the given path,
+// shared for readers and exclusive for writers. Call the returned func to
+// release it.
+func lockFile(path string, exclusive bool) (unlock func(), err error) {
+	lockPath := path + ".lock"
+
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, filePerm)
+	if err != nil {
+		if os.IsNotExist(err) && !exclusive {
+			// Cache dir doesn't exist yet, so there's nothing to lock.
+			return func() {}, nil
+		}
+		return nil, errors.Wrapf(err, "could not open lock file %s", lockPath)
+	}
+
+	err = flock(f, exclusive)
+	if err != nil {
+		f.Close()
+		return nil, errors.Wrapf(err, "could not lock %s", lockPath)
+	}
+
+	return func() {
+		funlock(f)
+		f.Close()
+	}, nil
+}
+
 var (
 	wordCharsOnly = regexp.MustCompile(`\W+`)
 )
@@ -2,6 +2,12 @@ package jsoncache
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -47,4 +53,500 @@ func TestReadWriteJSON(t *testing.T) {
 	r.Equal(data.Gai, data2.Gai)
 
 	r.Equal(ErrNotFound, Get(key2, &data2, InvalidateWeekly))
+
+	r.NoError(Delete(key1, InvalidateWeekly))
+	r.Equal(ErrNotFound, Get(key1, &data2, InvalidateWeekly))
+	r.NoError(Delete(key2, InvalidateWeekly))
+}
+
+func TestConcurrentReadWriteJSON(t *testing.T) {
+	r := require.New(t)
+
+	defer func(dir string) { Dir = dir }(Dir)
+	Dir = t.TempDir()
+
+	type payload struct {
+		Writer int      `json:"writer"`
+		Values []string `json:"values"`
+	}
+
+	// Big enough to make interleaved writes likely without atomic renames.
+	newPayload := func(writer int) payload {
+		p := payload{Writer: writer}
+		for i := 0; i < 2000; i++ {
+			p.Values = append(p.Values, strings.Repeat(fmt.Sprintf("%d", writer), 10))
+		}
+		return p
+	}
+
+	key := "testing/stress"
+	goroutines := 50
+	iterations := 20
+
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines*iterations*2)
+
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+
+			for i := 0; i < iterations; i++ {
+				if err := Set(key, newPayload(g), InvalidateWeekly); err != nil {
+					errs <- err
+					continue
+				}
+
+				var got payload
+				if err := Get(key, &got, InvalidateWeekly); err != nil {
+					errs <- err
+					continue
+				}
+				if len(got.Values) != 2000 || got.Values[0] != newPayload(got.Writer).Values[0] {
+					errs <- fmt.Errorf("got corrupted payload from writer %d", got.Writer)
+				}
+			}
+		}(g)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		r.NoError(err)
+	}
+
+	// No temp files should be left behind.
+	tmps, err := filepath.Glob(filepath.Join(Dir, ".*.tmp-*"))
+	r.NoError(err)
+	r.Empty(tmps)
+
+	// Cache files should not be world-writable.
+	fi, err := os.Stat(filepath.Join(Dir, createKey(key, InvalidateWeekly)))
+	r.NoError(err)
+	r.Equal(filePerm, fi.Mode().Perm())
+}
+
+func TestDo(t *testing.T) {
+	r := require.New(t)
+
+	key := "testing/do"
+	goroutines := 20
+
+	var calls int32
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines)
+	results := make([]interface{}, goroutines)
+
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+
+			v, err, _ := Do(key, func() (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				<-release
+				return "fetched", nil
+			})
+			if err != nil {
+				errs <- err
+				return
+			}
+			results[g] = v
+		}(g)
+	}
+
+	// Hold the first call until all others are waiting on it.
+	waiting := func() int {
+		flights.mu.Lock()
+		defer flights.mu.Unlock()
+
+		if c, ok := flights.m[key]; ok {
+			return c.dup
+		}
+		return 0
+	}
+	for waiting() < goroutines-1 {
+		runtime.Gosched()
+	}
+	close(release)
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		r.NoError(err)
+	}
+	r.Equal(int32(1), atomic.LoadInt32(&calls))
+	for _, v := range results {
+		r.Equal("fetched", v)
+	}
+}
+
+func TestDoPanic(t *testing.T) {
+	r := require.New(t)
+
+	key := "testing/do-panic"
+	release := make(chan struct{})
+	errs := make(chan error, 2)
+
+	for g := 0; g < 2; g++ {
+		go func() {
+			_, err, _ := Do(key, func() (interface{}, error) {
+				<-release
+				panic("boom")
+			})
+			errs <- err
+		}()
+	}
+
+	// Hold the first call until the other is waiting on it.
+	waiting := func() int {
+		flights.mu.Lock()
+		defer flights.mu.Unlock()
+
+		if c, ok := flights.m[key]; ok {
+			return c.dup
+		}
+		return 0
+	}
+	for waiting() < 1 {
+		runtime.Gosched()
+	}
+	close(release)
+
+	for g := 0; g < 2; g++ {
+		r.EqualError(<-errs, "jsoncache: fetch for testing/do-panic panicked: boom")
+	}
+}
+
+func TestGetOrFetch(t *testing.T) {
+	r := require.New(t)
+
+	defer func(dir string) { Dir = dir }(Dir)
+	Dir = t.TempDir()
+
+	type coin struct {
+		ID     string    `json:"id"`
+		Prices []float64 `json:"prices"`
+	}
+
+	var fetches int
+	fetch := func() (interface{}, error) {
+		fetches++
+		return &coin{"terra-luna", []float64{1, 2, 3}}, nil
+	}
+
+	var c1 *coin
+	hit, err := GetOrFetch("testing/get-or-fetch", InvalidateDaily, &c1, fetch)
+	r.NoError(err)
+	r.False(hit)
+	r.Equal("terra-luna", c1.ID)
+
+	var c2 *coin
+	hit, err = GetOrFetch("testing/get-or-fetch", InvalidateDaily, &c2, fetch)
+	r.NoError(err)
+	r.True(hit)
+	r.Equal(c1, c2)
+	r.NotSame(c1, c2)
+	r.Equal(1, fetches)
+
+	// Fetched values must match the type we're reading into.
+	var wrong []string
+	_, err = GetOrFetch("testing/get-or-fetch-wrong-type", InvalidateDaily, &wrong, fetch)
+	r.Error(err)
+
+	// Fetch errors are returned and nothing is cached.
+	var c3 *coin
+	_, err = GetOrFetch("testing/get-or-fetch-error", InvalidateDaily, &c3, func() (interface{}, error) {
+		return nil, fmt.Errorf("boom")
+	})
+	r.EqualError(err, "boom")
+	r.Equal(ErrNotFound, Get("testing/get-or-fetch-error", &c3, InvalidateDaily))
+}
+
+func TestGetOrFetchShared(t *testing.T) {
+	r := require.New(t)
+
+	defer func(dir string) { Dir = dir }(Dir)
+	Dir = t.TempDir()
+
+	key := "testing/get-or-fetch-shared"
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	results := make([][]float64, 2)
+
+	for g := range results {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+
+			_, err := GetOrFetch(key, InvalidateDaily, &results[g], func() (interface{}, error) {
+				<-release
+				return []float64{1, 2, 3}, nil
+			})
+			if err != nil {
+				errs <- err
+			}
+		}(g)
+	}
+
+	// Hold the fetch until the other call waits on it.
+	waiting := func() int {
+		flights.mu.Lock()
+		defer flights.mu.Unlock()
+
+		if c, ok := flights.m[createKey(key, InvalidateDaily)]; ok {
+			return c.dup
+		}
+		return 0
+	}
+	for waiting() < 1 {
+		runtime.Gosched()
+	}
+	close(release)
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		r.NoError(err)
+	}
+
+	// Both callers got their own copy.
+	results[0][0] = 100
+	r.Equal([]float64{1, 2, 3}, results[1])
+}
+
+func TestCompress(t *testing.T) {
+	r := require.New(t)
+
+	defer func(dir string, compress bool) { Dir, Compress = dir, compress }(Dir, Compress)
+	Dir = t.TempDir()
+
+	data := []string{strings.Repeat("luna", 1000), strings.Repeat("osmo", 1000)}
+
+	Compress = false
+	r.NoError(Set("testing/plain", data, InvalidateDaily))
+
+	Compress = true
+	r.NoError(Set("testing/gzip", data, InvalidateDaily))
+
+	plain, err := os.ReadFile(filepath.Join(Dir, createKey("testing/plain", InvalidateDaily)))
+	r.NoError(err)
+	gz, err := os.ReadFile(filepath.Join(Dir, createKey("testing/gzip", InvalidateDaily)))
+	r.NoError(err)
+
+	r.Equal(byte('['), plain[0])
+	r.Equal([]byte{0x1f, 0x8b}, gz[:2])
+	r.Less(len(gz), len(plain))
+
+	// Both formats are readable regardless of the current setting.
+	for _, compress := range []bool{false, true} {
+		Compress = compress
+
+		var got []string
+		r.NoError(Get("testing/plain", &got, InvalidateDaily))
+		r.Equal(data, got)
+
+		got = nil
+		r.NoError(Get("testing/gzip", &got, InvalidateDaily))
+		r.Equal(data, got)
+	}
+}
+
+func TestListAndClear(t *testing.T) {
+	r := require.New(t)
+
+	defer func(dir string) { Dir = dir }(Dir)
+	Dir = t.TempDir()
+
+	r.NoError(Set("coingecko/terra-luna-365-days-usd", []float64{1, 2}, InvalidateDaily))
+	r.NoError(Set("coingecko/osmosis-365-days-usd", []float64{3, 4}, InvalidateDaily))
+	r.NoError(Set("messari/assets", []string{"btc"}, InvalidateMonthly))
+	r.NoError(Set("no-source", "abc", InvalidateHourly))
+
+	es, err := List()
+	r.NoError(err)
+	r.Len(es, 4)
+
+	r.Equal("coingecko/osmosis-365-days-usd", es[0].Key)
+	r.Equal("coingecko", es[0].Source)
+	r.Equal(createKey("coingecko/osmosis-365-days-usd", InvalidateDaily), es[0].File)
+	r.Equal(InvalidateDaily, es[0].Period)
+	r.Equal(int64(len("[3,4]")), es[0].Size)
+	r.False(es[0].Expired())
+	r.True(es[0].ExpiresAt.After(es[0].CreatedAt))
+	r.Equal("", es[3].Source)
+
+	raw, err := ReadRaw(es[2])
+	r.NoError(err)
+	r.Equal(`["btc"]`, string(raw))
+
+	removed, err := Clear(func(e *Entry) bool { return e.Source == "coingecko" })
+	r.NoError(err)
+	r.Len(removed, 2)
+
+	es, err = List()
+	r.NoError(err)
+	r.Len(es, 2)
+	r.Equal("messari/assets", es[0].Key)
+
+	var luna []float64
+	r.Equal(ErrNotFound, Get("coingecko/terra-luna-365-days-usd", &luna, InvalidateDaily))
+}
+
+func TestExpiresAt(t *testing.T) {
+	r := require.New(t)
+
+	created := time.Date(2022, 3, 30, 10, 30, 0, 0, time.UTC) // A Wednesday.
+
+	r.Equal(time.Date(2022, 3, 30, 11, 0, 0, 0, time.UTC), expiresAt(created, InvalidateHourly).UTC())
+	r.Equal(created, expiresAt(created, InvalidateNow))
+
+	local := created.Local()
+	r.Equal(time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, time.Local), expiresAt(created, InvalidateDaily))
+	r.Equal(time.Date(local.Year(), local.Month()+1, 1, 0, 0, 0, 0, time.Local), expiresAt(created, InvalidateMonthly))
+
+	// The month ends before the ISO week does.
+	r.Equal(expiresAt(created, InvalidateMonthly), expiresAt(created, InvalidateWeekly))
+
+	// Next Monday comes before the end of the month.
+	created = time.Date(2022, 3, 9, 10, 30, 0, 0, time.UTC)
+	r.Equal(time.Date(2022, 3, 14, 0, 0, 0, 0, time.UTC), expiresAt(created, InvalidateWeekly).UTC())
+}
+
+func TestMemoryCache(t *testing.T) {
+	r := require.New(t)
+
+	defer func(dir string) { Dir = dir }(Dir)
+	Dir = t.TempDir()
+
+	defer DisableMemoryCache()
+	EnableMemoryCache(2, 0)
+
+	type coin struct {
+		ID     string    `json:"id"`
+		Prices []float64 `json:"prices"`
+	}
+
+	r.NoError(Set("testing/a", coin{"a", []float64{1}}, InvalidateDaily))
+	r.NoError(Set("testing/b", coin{"b", []float64{2}}, InvalidateDaily))
+
+	// Served from memory.
+	var a coin
+	r.NoError(Get("testing/a", &a, InvalidateDaily))
+	r.Equal("a", a.ID)
+	r.Equal(MemoryStats{Hits: 1, Entries: 2, Bytes: 2 * int64(len(`{"id":"a","prices":[1]}`))}, Stats())
+
+	// Evicts b, the least recently used entry.
+	r.NoError(Set("testing/c", coin{"c", []float64{3}}, InvalidateDaily))
+	r.Equal(uint64(1), Stats().Evictions)
+
+	// Read from disk and put back in memory, evicting a.
+	var b coin
+	r.NoError(Get("testing/b", &b, InvalidateDaily))
+	r.Equal("b", b.ID)
+	r.Equal(uint64(1), Stats().Misses)
+	r.Equal(uint64(2), Stats().Evictions)
+
+	r.NoError(Get("testing/b", &b, InvalidateDaily))
+	r.Equal(uint64(2), Stats().Hits)
+
+	// Every Get decodes a copy, so modifying it leaves the cache alone.
+	b.Prices[0] = 100
+	var b2 coin
+	r.NoError(Get("testing/b", &b2, InvalidateDaily))
+	r.Equal([]float64{2}, b2.Prices)
+	r.Equal(uint64(3), Stats().Hits)
+
+	// Including into a different type.
+	var m map[string]interface{}
+	r.NoError(Get("testing/b", &m, InvalidateDaily))
+	r.Equal("b", m["id"])
+	r.Equal(uint64(4), Stats().Hits)
+	r.Equal(uint64(1), Stats().Misses)
+
+	// Removed entries are dropped from memory too.
+	es, err := List()
+	r.NoError(err)
+	_, err = Clear(func(e *Entry) bool { return true })
+	r.NoError(err)
+	r.Len(es, 3)
+	r.Equal(0, Stats().Entries)
+	r.Equal(ErrNotFound, Get("testing/b", &b, InvalidateDaily))
+}
+
+func TestMemoryCacheMaxBytes(t *testing.T) {
+	r := require.New(t)
+
+	defer func(dir string) { Dir = dir }(Dir)
+	Dir = t.TempDir()
+
+	defer DisableMemoryCache()
+	EnableMemoryCache(0, 10)
+
+	r.NoError(Set("testing/small", "abc", InvalidateDaily)) // 5 bytes
+	r.NoError(Set("testing/big", strings.Repeat("x", 20), InvalidateDaily))
+
+	// The big entry can never fit, so it skips the memory cache and the
+	// small one survives.
+	r.Equal(1, Stats().Entries)
+	r.Equal(int64(5), Stats().Bytes)
+	r.Equal(uint64(0), Stats().Evictions)
+
+	var s string
+	r.NoError(Get("testing/small", &s, InvalidateDaily))
+	r.Equal("abc", s)
+	r.Equal(uint64(1), Stats().Hits)
+
+	r.NoError(Get("testing/big", &s, InvalidateDaily))
+	r.Equal(strings.Repeat("x", 20), s)
+	r.Equal(1, Stats().Entries)
+	r.Equal(int64(5), Stats().Bytes)
+}
+
+func TestMemoryCacheConcurrentGet(t *testing.T) {
+	r := require.New(t)
+
+	defer func(dir string) { Dir = dir }(Dir)
+	Dir = t.TempDir()
+
+	defer DisableMemoryCache()
+	EnableMemoryCache(10, 0)
+
+	r.NoError(Set("testing/shared", []float64{1, 2, 3}, InvalidateDaily))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20*100)
+
+	for g := 0; g < 20; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				var v []float64
+				if err := Get("testing/shared", &v, InvalidateDaily); err != nil {
+					errs <- err
+					continue
+				}
+				if len(v) != 3 {
+					errs <- fmt.Errorf("got %d values, expected 3", len(v))
+				}
+				v[0] = float64(i)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		r.NoError(err)
+	}
+
+	r.Equal(uint64(2000), Stats().Hits)
 }
@@ -0,0 +1,18 @@
+//go:build windows
+// +build windows
+
+package jsoncache
+
+import "os"
+
+// Advisory locking isn't implemented on Windows. Writes are still atomic
+// thanks to write-to-temp-and-rename, but concurrent writers from several
+// processes aren't serialized.
+
+func flock(f *os.File, exclusive bool) error {
+	return nil
+}
+
+func funlock(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,153 @@
+package jsoncache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// MemoryStats holds diagnostics for the in-memory cache tier.
+type MemoryStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Entries   int    `json:"entries"`
+	Bytes     int64  `json:"bytes"` // Size of the cached JSON
+}
+
+type memoryItem struct {
+	key string
+	b   []byte
+}
+
+// lru is a least-recently-used cache of JSON payloads, bounded by number
+// of entries and/or total bytes.
+type lru struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxBytes   int64
+	ll         *list.List
+	items      map[string]*list.Element
+	stats      MemoryStats
+}
+
+var (
+	memoryMu sync.RWMutex
+	memory   *lru
+)
+
+// EnableMemoryCache puts an in-memory LRU cache in front of the file
+// store, so that repeated Gets of the same key skip locking, reading and
+// decompressing the file. The cache holds at most maxEntries payloads and
+// maxBytes of (uncompressed) JSON; a limit <= 0 means no limit on that
+// dimension. Payloads larger than maxBytes are only kept on disk.
+//
+// Payloads are decoded on every Get, so callers never share values and
+// may modify what they get. Writes by other processes aren't seen until
+// the in-memory entry is evicted.
+func EnableMemoryCache(maxEntries int, maxBytes int64) {
+	memoryMu.Lock()
+	defer memoryMu.Unlock()
+
+	memory = &lru{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// DisableMemoryCache drops the in-memory cache tier and all its entries.
+func DisableMemoryCache() {
+	memoryMu.Lock()
+	defer memoryMu.Unlock()
+
+	memory = nil
+}
+
+// Stats returns hit/miss counters and the current size of the in-memory
+// cache tier. It returns zero stats if the tier isn't enabled.
+func Stats() MemoryStats {
+	c := memoryCache()
+	if c == nil {
+		return MemoryStats{}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.stats
+}
+
+func memoryCache() *lru {
+	memoryMu.RLock()
+	defer memoryMu.RUnlock()
+
+	return memory
+}
+
+// get returns the JSON cached for key.
+func (c *lru) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.ll.MoveToFront(el)
+		c.stats.Hits++
+		return el.Value.(*memoryItem).b, true
+	}
+
+	c.stats.Misses++
+	return nil, false
+}
+
+func (c *lru) add(key string, b []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	size := int64(len(b))
+
+	if c.maxBytes > 0 && size > c.maxBytes {
+		// Would evict everything else and still not fit.
+		if el, ok := c.items[key]; ok {
+			c.removeElement(el)
+		}
+		return
+	}
+
+	if el, ok := c.items[key]; ok {
+		item := el.Value.(*memoryItem)
+		c.stats.Bytes += size - int64(len(item.b))
+		item.b = b
+		c.ll.MoveToFront(el)
+	} else {
+		c.items[key] = c.ll.PushFront(&memoryItem{key, b})
+		c.stats.Entries++
+		c.stats.Bytes += size
+	}
+
+	for c.ll.Len() > 0 && c.overLimit() {
+		c.removeElement(c.ll.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *lru) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+func (c *lru) overLimit() bool {
+	return (c.maxEntries > 0 && c.ll.Len() > c.maxEntries) ||
+		(c.maxBytes > 0 && c.stats.Bytes > c.maxBytes)
+}
+
+func (c *lru) removeElement(el *list.Element) {
+	item := c.ll.Remove(el).(*memoryItem)
+	delete(c.items, item.key)
+	c.stats.Entries--
+	c.stats.Bytes -= int64(len(item.b))
+}
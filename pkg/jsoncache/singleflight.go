@@ -0,0 +1,69 @@
+package jsoncache
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// call is an in-flight or completed Do call.
+type call struct {
+	wg  sync.WaitGroup
+	val interface{}
+	err error
+	dup int
+}
+
+type group struct {
+	mu sync.Mutex
+	m  map[string]*call
+}
+
+var flights = &group{m: make(map[string]*call)}
+
+// Do executes and returns the results of fn, making sure that only one
+// execution is in-flight for a given key at a time. If a duplicate call
+// comes in, the duplicate caller waits for the original to complete and
+// receives the same results. The return value shared reports whether v
+// was given to multiple callers. A panic in fn is returned as an error to
+// all callers.
+func Do(key string, fn func() (interface{}, error)) (v interface{}, err error, shared bool) {
+	return flights.do(key, fn)
+}
+
+func (g *group) do(key string, fn func() (interface{}, error)) (interface{}, error, bool) {
+	g.mu.Lock()
+	if c, ok := g.m[key]; ok {
+		c.dup++
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.val, c.err, true
+	}
+	c := new(call)
+	c.wg.Add(1)
+	g.m[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.m, key)
+		g.mu.Unlock()
+		c.wg.Done()
+	}()
+
+	func() {
+		// Waiters would otherwise get no value and no error.
+		defer func() {
+			if p := recover(); p != nil {
+				c.val, c.err = nil, errors.Errorf("jsoncache: fetch for %s panicked: %v", key, p)
+			}
+		}()
+		c.val, c.err = fn()
+	}()
+
+	g.mu.Lock()
+	shared := c.dup > 0
+	g.mu.Unlock()
+
+	return c.val, c.err, shared
+}
@@ -0,0 +1,290 @@
+package messari
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// DefaultAssetFields are the fields fetched by Assets unless others are
+// given. Nested fields are separated by slashes, e.g.
+// "metrics/market_data/price_usd".
+var DefaultAssetFields = []string{
+	"id",
+	"symbol",
+	"name",
+	"slug",
+	"metrics/market_data",
+	"metrics/marketcap",
+	"metrics/supply",
+	"metrics/all_time_high",
+	"metrics/roi_data",
+	"metrics/developer_activity",
+	"metrics/on_chain_data",
+}
+
+type Asset struct {
+	ID      string  `json:"id"`     // "1e31218a-e44e-4285-820c-8282ee222035",
+	Symbol  string  `json:"symbol"` // "BTC",
+	Name    string  `json:"name"`   // "Bitcoin",
+	Slug    string  `json:"slug"`   // "bitcoin",
+	Metrics Metrics `json:"metrics"`
+
+	// raw holds the asset as returned by the API, including fields that
+	// aren't modeled above, and decoded the modeled fields as they were
+	// decoded from it. See Value.
+	raw     map[string]interface{}
+	decoded *asset
+}
+
+type Metrics struct {
+	MarketData        MarketData        `json:"market_data"`
+	Marketcap         Marketcap         `json:"marketcap"`
+	Supply            Supply            `json:"supply"`
+	AllTimeHigh       AllTimeHigh       `json:"all_time_high"`
+	ROIData           ROIData           `json:"roi_data"`
+	DeveloperActivity DeveloperActivity `json:"developer_activity"`
+	OnChainData       OnChainData       `json:"on_chain_data"`
+}
+
+type MarketData struct {
+	PriceUSD                    float64 `json:"price_usd"`                        // 38543.12
+	PriceBTC                    float64 `json:"price_btc"`                        // 1
+	PriceETH                    float64 `json:"price_eth"`                        // 14.12
+	VolumeLast24Hours           float64 `json:"volume_last_24_hours"`             // 23431441129.24
+	RealVolumeLast24Hours       float64 `json:"real_volume_last_24_hours"`        // 2345551129.63
+	PercentChangeUSDLast1Hour   float64 `json:"percent_change_usd_last_1_hour"`   // -0.21
+	PercentChangeUSDLast24Hours float64 `json:"percent_change_usd_last_24_hours"` // 2.35
+}
+
+type Marketcap struct {
+	Rank                      int     `json:"rank"`                                  // 1
+	MarketcapDominancePercent float64 `json:"marketcap_dominance_percent"`           // 42.12
+	CurrentMarketcapUSD       float64 `json:"current_marketcap_usd"`                 // 731163040353.98
+	Y2050MarketcapUSD         float64 `json:"y_2050_marketcap_usd"`                  // 808862139932.12
+	YPlus10MarketcapUSD       float64 `json:"y_plus10_marketcap_usd"`                // 800234098321.55
+	LiquidMarketcapUSD        float64 `json:"liquid_marketcap_usd"`                  // 731163040353.98
+	VolumeTurnoverLast24Hours float64 `json:"volume_turnover_last_24_hours_percent"` // 3.21
+	RealizedMarketcapUSD      float64 `json:"realized_marketcap_usd"`                // 457219187023.18
+}
+
+type Supply struct {
+	Y2050              float64 `json:"y_2050"`                   // 20983495.3984375,
+	Y2050IssuedPct     float64 `json:"y_2050_issued_percent"`    // 20,
+	YPlus10            float64 `json:"y_plus10"`                 // 8932344.3984375,
+	YPlus10IssuedPct   float64 `json:"y_plus10_issued_percent"`  // 40,
+	Liquid             float64 `json:"liquid"`                   // 1982345,
+	Circulating        float64 `json:"circulating"`              // 17394725,
+	StockToFlow        float64 `json:"stock_to_flow"`            // 0
+	AnnualInflationPct float64 `json:"annual_inflation_percent"` //  1.7633
+}
+
+type AllTimeHigh struct {
+	Price             float64 `json:"price"`              // 20089,
+	At                string  `json:"at"`                 // "2018-06-02T22:51:28.209Z",
+	DaysSince         int     `json:"days_since"`         // 344,
+	PercentDown       float64 `json:"percent_down"`       // 81.47285775644839
+	BreakevenMultiple float64 `json:"breakeven_multiple"` // 5.3967
+}
+
+type ROIData struct {
+	PercentChangeLast1Week   float64 `json:"percent_change_last_1_week"`   // -2.45
+	PercentChangeLast1Month  float64 `json:"percent_change_last_1_month"`  // 12.03
+	PercentChangeLast3Months float64 `json:"percent_change_last_3_months"` // -14.71
+	PercentChangeLast1Year   float64 `json:"percent_change_last_1_year"`   // 58.12
+}
+
+type DeveloperActivity struct {
+	Stars                   int `json:"stars"`                       // 34996,
+	Watchers                int `json:"watchers"`                    // 3513,
+	CommitsLast3Months      int `json:"commits_last_3_months"`       // 342,
+	CommitsLast1Year        int `json:"commits_last_1_year"`         // 1775,
+	LinesAddedLast3Months   int `json:"lines_added_last_3_months"`   // 12030,
+	LinesDeletedLast3Months int `json:"lines_deleted_last_3_months"` // 8001,
+}
+
+type OnChainData struct {
+	TxnCountLast24Hours      float64 `json:"txn_count_last_24_hours"`      // 274301
+	TransferCountLast24Hours float64 `json:"transfer_count_last_24_hours"` // 533843
+	ActiveAddresses          float64 `json:"active_addresses"`             // 897441
+	AverageFeeUSD            float64 `json:"average_fee_usd"`              // 1.65
+	MedianFeeUSD             float64 `json:"median_fee_usd"`               // 0.63
+	AdjustedNVT              float64 `json:"adjusted_nvt"`                 // 47.35
+}
+
+type asset Asset // Same fields without the JSON methods, to avoid recursion.
+
+func (a *Asset) UnmarshalJSON(b []byte) error {
+	err := json.Unmarshal(b, (*asset)(a))
+	if err != nil {
+		return err
+	}
+
+	decoded := *(*asset)(a)
+	decoded.raw, decoded.decoded = nil, nil
+	a.decoded = &decoded
+
+	a.raw = nil
+	return json.Unmarshal(b, &a.raw)
+}
+
+// MarshalJSON returns the asset as it was returned by the API with any
+// changes to the modeled fields, so that fields that aren't modeled
+// survive a trip through the cache.
+func (a *Asset) MarshalJSON() ([]byte, error) {
+	if a.raw == nil {
+		return json.Marshal((*asset)(a))
+	}
+	raw, err := a.merged()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// merged returns the raw fields with the modeled fields that were changed
+// since decoding them written over them. Modeled fields that are unchanged
+// are left out, so that fields the API didn't return stay missing.
+func (a *Asset) merged() (map[string]interface{}, error) {
+	before, err := toMap(a.decoded)
+	if err != nil {
+		return nil, err
+	}
+	after, err := toMap((*asset)(a))
+	if err != nil {
+		return nil, err
+	}
+
+	return mergeChanges(a.raw, before, after), nil
+}
+
+func toMap(v interface{}) (m map[string]interface{}, err error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, &m)
+	return
+}
+
+// mergeChanges returns a copy of raw with the values in after that differ
+// from those in before.
+func mergeChanges(raw, before, after map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(raw))
+	for k, v := range raw {
+		merged[k] = v
+	}
+
+	for k, v := range after {
+		if m, ok := v.(map[string]interface{}); ok {
+			r, _ := raw[k].(map[string]interface{})
+			b, _ := before[k].(map[string]interface{})
+			if changed := mergeChanges(r, b, m); len(changed) > 0 {
+				merged[k] = changed
+			}
+			continue
+		}
+		if !reflect.DeepEqual(v, before[k]) {
+			merged[k] = v
+		}
+	}
+
+	return merged
+}
+
+// Value returns the field at the given path, with nested fields separated
+// by slashes (as in the Messari `fields` query param) or dots, e.g.
+// "metrics/supply/stock_to_flow" or "metrics.mining_stats.hash_rate".
+func (a *Asset) Value(path string) (v interface{}, found bool) {
+	keys := strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '.' })
+
+	index, modeled := modeledFields[strings.Join(keys, ".")]
+	if !modeled {
+		return lookup(a.raw, keys)
+	}
+
+	field := reflect.ValueOf(a).Elem().FieldByIndex(index)
+	if field.Kind() == reflect.Struct {
+		// May hold both changed and unmodeled fields.
+		var m map[string]interface{}
+		var err error
+		if a.raw != nil {
+			m, err = a.merged()
+		} else {
+			m, err = toMap((*asset)(a))
+		}
+		if err != nil {
+			return nil, false
+		}
+		return lookup(m, keys)
+	}
+
+	if a.raw == nil || field.Interface() != reflect.ValueOf(a.decoded).Elem().FieldByIndex(index).Interface() {
+		// Asset wasn't decoded from JSON or the field was changed since.
+		return jsonValue(field), true
+	}
+	return lookup(a.raw, keys)
+}
+
+func lookup(m map[string]interface{}, keys []string) (v interface{}, found bool) {
+	var cur interface{} = m
+	for _, key := range keys {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		cur, ok = m[key]
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return cur, cur != nil
+}
+
+// Float returns the numeric field at the given path, see Value.
+func (a *Asset) Float(path string) (f float64, found bool) {
+	v, found := a.Value(path)
+	if !found {
+		return 0, false
+	}
+	f, found = v.(float64)
+	return
+}
+
+// modeledFields maps the paths of the modeled fields of an asset, joined by
+// dots, to their index for reflect.Value.FieldByIndex.
+var modeledFields = fieldIndexes(reflect.TypeOf(asset{}), "", nil)
+
+func fieldIndexes(t reflect.Type, prefix string, index []int) map[string][]int {
+	m := make(map[string][]int)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if f.PkgPath != "" || name == "" {
+			continue
+		}
+
+		path := prefix + name
+		fi := append(append([]int{}, index...), i)
+		m[path] = fi
+
+		if f.Type.Kind() == reflect.Struct {
+			for p, fi := range fieldIndexes(f.Type, path+".", fi) {
+				m[p] = fi
+			}
+		}
+	}
+	return m
+}
+
+// jsonValue returns v as it would be decoded from JSON into an interface{}.
+func jsonValue(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	}
+	return v.Interface()
+}
@@ -22,105 +22,281 @@ const (
 )
 
 func New(token string) *Messari {
-	return &Messari{token}
+	return &Messari{
+		MaxRetries: 5,
+		Backoff:    2 * time.Second,
+		MaxBackoff: 2 * time.Minute,
+		token:      token,
+		baseURI:    apiBaseURI,
+		sleep:      time.Sleep,
+	}
 }
 
 type Messari struct {
-	token string
+	MaxRetries int           // Max number of retries of a request that was rate limited or failed with a 5xx error
+	Backoff    time.Duration // Wait before the first retry, doubled for every retry after that
+	MaxBackoff time.Duration // Max wait between retries; the API asking for longer is an error
+
+	token   string
+	baseURI string
+	sleep   func(time.Duration)
 }
 
-func (cg *Messari) AssetsWithCache(i jsoncache.InvalidateCachePeriod) (as []*Asset, err error) {
-	key := "messari-assets"
+type AssetsOptions struct {
+	Fields   []string // Fields to fetch, e.g. "metrics/supply" (default: DefaultAssetFields)
+	PerPage  int      // Number of assets per page, max 500
+	MaxPages int      // Stop after fetching this many pages (0 = no limit)
 
-	err = jsoncache.Get(key, &as, i)
-	if err != nil {
-		if err != jsoncache.ErrNotFound {
-			return nil, err
-		}
+	// Progress resumes pagination from a previous, interrupted call, e.g.
+	// one that was rate limited until it ran out of retries.
+	Progress *AssetsProgress
 
-		// Perform call.
-		as, err = cg.Assets()
-		if err != nil {
+	// OnPage is called after every page with the progress so far, e.g. to
+	// persist it. Returning an error stops pagination.
+	OnPage func(p *AssetsProgress) error
+}
+
+type AssetsProgress struct {
+	NextPage int      `json:"next_page"`
+	Done     bool     `json:"done"`
+	Assets   []*Asset `json:"assets,omitempty"`
+}
+
+func DefaultAssetsOptions() AssetsOptions {
+	return AssetsOptions{PerPage: 500}
+}
+
+// AssetsWithCache returns all assets with the given fields (default:
+// DefaultAssetFields), caching them for the given period.
+func (cg *Messari) AssetsWithCache(i jsoncache.InvalidateCachePeriod, fields ...string) (as []*Asset, err error) {
+	key := "messari/assets"
+	if len(fields) > 0 {
+		key += "-" + strings.Join(fields, "-")
+	}
+
+	o := DefaultAssetsOptions()
+	o.Fields = fields
+
+	return cg.assetsWithCache(key, i, o)
+}
+
+// assetsWithCache persists the page cursor and every new page as they're
+// fetched so that a run that fails halfway through can pick up where it
+// left off, and removes them once all assets are cached.
+func (cg *Messari) assetsWithCache(key string, i jsoncache.InvalidateCachePeriod, o AssetsOptions) (as []*Asset, err error) {
+	progressKey := key + "-progress"
+	pageKey := func(page int) string {
+		return fmt.Sprintf("%s-page-%d", key, page)
+	}
+
+	var next int // Page after the last one persisted
+	hit, err := jsoncache.GetOrFetch(key, i, &as, func() (interface{}, error) {
+		o.Progress = new(AssetsProgress)
+		err := jsoncache.Get(progressKey, o.Progress, i)
+		if err != nil && err != jsoncache.ErrNotFound {
 			return nil, err
 		}
+		for page := 1; page < o.Progress.NextPage; page++ {
+			var assets []*Asset
+			err = jsoncache.Get(pageKey(page), &assets, i)
+			if err == jsoncache.ErrNotFound {
+				// Start over rather than return a gap.
+				o.Progress = new(AssetsProgress)
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			o.Progress.Assets = append(o.Progress.Assets, assets...)
+		}
+		next = o.Progress.NextPage
+
+		saved := len(o.Progress.Assets)
+		onPage := o.OnPage
+		o.OnPage = func(p *AssetsProgress) error {
+			if len(p.Assets) > saved {
+				err := jsoncache.Set(pageKey(p.NextPage-1), p.Assets[saved:], i)
+				if err != nil {
+					return err
+				}
+				saved = len(p.Assets)
+			}
+			err := jsoncache.Set(progressKey, &AssetsProgress{NextPage: p.NextPage, Done: p.Done}, i)
+			if err != nil {
+				return err
+			}
+			next = p.NextPage
+			if onPage != nil {
+				return onPage(p)
+			}
+			return nil
+		}
+
+		return cg.AssetsWithOptions(o)
+	})
+	if err != nil || hit {
+		return
+	}
 
-		// Cache result.
-		err = jsoncache.Set(key, as, i)
+	// All assets are cached, so the progress is no longer needed.
+	for page := 1; page < next; page++ {
+		err = jsoncache.Delete(pageKey(page), i)
 		if err != nil {
 			return nil, err
 		}
-		fmt.Printf("Downloaded data   : %s\n", key)
-
-	} else {
-		fmt.Printf("Using cached data : %s\n", key)
+	}
+	err = jsoncache.Delete(progressKey, i)
+	if err != nil {
+		return nil, err
 	}
 
 	return
 }
 
 func (cg *Messari) Assets() (as []*Asset, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*300)
-	defer cancel()
+	return cg.AssetsWithOptions(DefaultAssetsOptions())
+}
 
-	page := 1
+func (cg *Messari) AssetsWithOptions(o AssetsOptions) (as []*Asset, err error) {
+	if o.PerPage <= 0 || o.PerPage > 500 {
+		return nil, errors.Errorf("invalid page size %d, must be in range [1 - 500]", o.PerPage)
+	}
+
+	p := o.Progress
+	if p == nil {
+		p = new(AssetsProgress)
+	}
+	if p.NextPage < 1 {
+		p.NextPage = 1
+	}
+
+	fields := o.Fields
+	if len(fields) == 0 {
+		fields = DefaultAssetFields
+	}
+
+	for pages := 0; !p.Done; pages++ {
+		if o.MaxPages > 0 && pages >= o.MaxPages {
+			break
+		}
 
-	for {
 		u := url.URL{}
 		q := u.Query()
-		q.Add("fields", "id,symbol,name,slug,metrics/market_data/price_usd,metrics/supply,metrics/all_time_high")
-		q.Add("page", strconv.Itoa(page))
-		q.Add("limit", "500")
+		q.Add("fields", strings.Join(fields, ","))
+		q.Add("page", strconv.Itoa(p.NextPage))
+		q.Add("limit", strconv.Itoa(o.PerPage))
 		u.RawQuery = q.Encode()
 
 		url := "/v2/assets?" + u.RawQuery
 		// https://data.messari.io/api/v2/assets?fields=id,slug,symbol,metrics/market_data/price_usd
 
 		var resp AssetsResponse
-		var errResp ErrorResponse
 
-		errResp, err = cg.getJSON(ctx, apiBaseURI+url, nil, &resp)
+		err = cg.getJSONWithRetry(cg.baseURI+url, nil, &resp)
 		if err != nil {
-			if strings.Contains(errResp.Status.ErrorMessage, "Rate limit") {
-				fmt.Printf("Rate limited, retrying in 10 seconds ...")
-				time.Sleep(10 * time.Second)
-				continue
-			}
-			if errResp.Status.ErrorCode == 404 {
-				fmt.Printf("Got 404 error, assuming there are no more pages to fetch!")
+			var apiErr *APIError
+			if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound && p.NextPage > 1 {
+				// Messari returns a 404 when paging past the last page.
 				err = nil
-				break
+				p.Done = true
+			} else {
+				return nil, errors.Wrapf(err, "could not fetch assets page %d", p.NextPage)
 			}
-
-			return nil, errors.Wrapf(err, "could not fetch assets")
+		} else {
+			p.Assets = append(p.Assets, resp.Data...)
+			p.NextPage++
+			p.Done = len(resp.Data) < o.PerPage
 		}
 
-		if len(resp.Data) == 0 {
-			return
+		if o.OnPage != nil {
+			err = o.OnPage(p)
+			if err != nil {
+				return nil, errors.Wrapf(err, "stopped fetching assets after page %d", p.NextPage-1)
+			}
 		}
+	}
 
-		as = append(as, resp.Data...)
-		page += 1
+	return p.Assets, nil
+}
+
+// APIError is returned for HTTP error responses from the Messari API.
+type APIError struct {
+	StatusCode int
+	Response   ErrorResponse // Parsed error body, if the body was JSON
+	Body       string        // Raw error body, if the body wasn't JSON (truncated)
+	RetryAfter time.Duration // How long the API asked us to wait before retrying (0 if unknown)
+}
+
+func (e *APIError) Error() string {
+	msg := e.Response.Status.ErrorMessage
+	if msg == "" {
+		msg = e.Body
 	}
+	if msg == "" {
+		return fmt.Sprintf("got HTTP error code: %d", e.StatusCode)
+	}
+	return fmt.Sprintf("got HTTP error code: %d: %s", e.StatusCode, msg)
+}
 
-	return
+// Retryable reports whether the request may succeed if retried later.
+func (e *APIError) Retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests ||
+		e.StatusCode >= 500 ||
+		strings.Contains(e.Response.Status.ErrorMessage, "Rate limit")
 }
 
-func (cg *Messari) getJSON(ctx context.Context, url string, payload, response interface{}) (errResp ErrorResponse, err error) {
+// getJSONWithRetry retries requests that were rate limited or failed with
+// a 5xx error, waiting as long as the API asks us to or with exponential
+// backoff otherwise. It gives up if the API asks us to wait longer than
+// MaxBackoff.
+func (cg *Messari) getJSONWithRetry(url string, payload, response interface{}) error {
+	backoff := cg.Backoff
+
+	for retry := 0; ; retry++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*60)
+		err := cg.getJSON(ctx, url, payload, response)
+		cancel()
+
+		var apiErr *APIError
+		if err == nil || !errors.As(err, &apiErr) || !apiErr.Retryable() {
+			return err
+		}
+		if retry >= cg.MaxRetries {
+			return errors.Wrapf(err, "giving up after %d retries", retry)
+		}
+
+		wait := backoff
+		if cg.MaxBackoff > 0 && wait > cg.MaxBackoff {
+			wait = cg.MaxBackoff
+		}
+		if apiErr.RetryAfter > 0 {
+			// Retrying any sooner would only get us rate limited again.
+			if cg.MaxBackoff > 0 && apiErr.RetryAfter > cg.MaxBackoff {
+				return errors.Wrapf(err, "API asked us to wait %s, longer than max backoff %s", apiErr.RetryAfter, cg.MaxBackoff)
+			}
+			wait = apiErr.RetryAfter
+		}
+
+		fmt.Printf("Got HTTP %d, retrying in %s (%d/%d) ...\n", apiErr.StatusCode, wait, retry+1, cg.MaxRetries)
+		cg.sleep(wait)
+
+		backoff *= 2
+	}
+}
+
+func (cg *Messari) getJSON(ctx context.Context, url string, payload, response interface{}) error {
 	var body io.Reader
 	if payload != nil {
-		var b []byte
-		b, err = json.Marshal(payload)
+		b, err := json.Marshal(payload)
 		if err != nil {
-			err = errors.Wrap(err, "could not marshal payload")
-			return
+			return errors.Wrap(err, "could not marshal payload")
 		}
 		body = bytes.NewReader(b)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, body)
 	if err != nil {
-		err = errors.Wrap(err, "could not create new HTTP request")
-		return
+		return errors.Wrap(err, "could not create new HTTP request")
 	}
 
 	req.Header.Add("accept", "application/json")
@@ -131,52 +307,85 @@ func (cg *Messari) getJSON(ctx context.Context, url string, payload, response in
 	c := http.Client{}
 	resp, err := c.Do(req)
 	if err != nil {
-		err = errors.Wrap(err, "could not execute HTTP request")
-		return
+		return errors.Wrap(err, "could not execute HTTP request")
 	}
+	defer resp.Body.Close()
 
-	if response != nil {
-		typ := resp.Header.Get("content-type")
-		if !strings.Contains(typ, "application/json") {
-			err = errors.Errorf("excepted response to have header `content-type: application/json` but got `%s`", typ)
-			return
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "could not read HTTP response body")
+	}
+
+	isJSON := strings.Contains(resp.Header.Get("content-type"), "application/json")
+
+	if resp.StatusCode >= 400 {
+		apiErr := &APIError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: retryAfter(resp.Header, time.Now()),
 		}
+		if !isJSON || json.Unmarshal(data, &apiErr.Response) != nil {
+			// E.g. an HTML error page from a proxy.
+			apiErr.Body = truncate(strings.TrimSpace(string(data)), 200)
+		}
+		return apiErr
+	}
 
-		var data []byte
-		data, err = ioutil.ReadAll(resp.Body)
-		if err != nil {
-			err = errors.Wrap(err, "could not read HTTP response body")
-			return
+	if response != nil {
+		if !isJSON {
+			return errors.Errorf("excepted response to have header `content-type: application/json` but got `%s`", resp.Header.Get("content-type"))
 		}
 
 		if false {
 			du := make(map[string]interface{})
 			err = json.Unmarshal(data, &du)
 			if err != nil {
-				err = errors.Wrap(err, "could not unmarshal HTTP response body")
-				return
+				return errors.Wrap(err, "could not unmarshal HTTP response body")
 			}
 			Dump(du)
 		}
 
-		if resp.StatusCode >= 400 {
-			err = json.Unmarshal(data, &errResp)
-			if err != nil {
-				err = errors.Wrap(err, "could not unmarshal HTTP error response body")
-				return
-			}
-			err = errors.Errorf("got HTTP error code: %d", resp.StatusCode)
-			return
-		}
-
 		err = json.Unmarshal(data, response)
 		if err != nil {
-			err = errors.Wrap(err, "could not unmarshal HTTP response body")
-			return
+			return errors.Wrap(err, "could not unmarshal HTTP response body")
 		}
 	}
 
-	return
+	return nil
+}
+
+// retryAfter returns how long to wait according to the `Retry-After`
+// header (seconds or an HTTP date) or the `x-ratelimit-reset` header
+// (seconds until reset, or a unix timestamp in seconds).
+func retryAfter(h http.Header, now time.Time) time.Duration {
+	if v := h.Get("Retry-After"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+			return time.Duration(secs) * time.Second
+		}
+		if t, err := http.ParseTime(v); err == nil && t.After(now) {
+			return t.Sub(now)
+		}
+	}
+
+	if v := h.Get("x-ratelimit-reset"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n >= 0 {
+			if n > 1_000_000_000 {
+				if t := time.Unix(n, 0); t.After(now) {
+					return t.Sub(now)
+				}
+				return 0
+			}
+			return time.Duration(n) * time.Second
+		}
+	}
+
+	return 0
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max] + "..."
 }
 
 func Dump(o interface{}) {
@@ -192,40 +401,6 @@ type AssetsResponse struct {
 	Data []*Asset `json:"data"`
 }
 
-type Asset struct {
-	ID      string `json:"id"`     // "1e31218a-e44e-4285-820c-8282ee222035",
-	Symbol  string `json:"symbol"` // "BTC",
-	Name    string `json:"name"`   // "Bitcoin",
-	Slug    string `json:"slug"`   // "bitcoin",
-	Metrics struct {
-		MarketData struct {
-			PriceUSD float64 `json:"price_usd"`
-		} `json:"market_data"`
-		Supply struct {
-			Y2050              float64 `json:"y_2050"`                   // 20983495.3984375,
-			Y2050IssuedPct     float64 `json:"y_2050_issued_percent"`    // 20,
-			YPlus10            float64 `json:"y_plus10"`                 // 8932344.3984375,
-			YPlus10IssuedPct   float64 `json:"y_plus10_issued_percent"`  // 40,
-			Liquid             float64 `json:"liquid"`                   // 1982345,
-			Circulating        float64 `json:"circulating"`              // 17394725,
-			StockToFlow        float64 `json:"stock_to_flow"`            // 0
-			AnnualInflationPct float64 `json:"annual_inflation_percent"` //  1.7633
-		} `json:"supply"`
-		DeveloperActivity struct {
-			Stars              int `json:"stars"`                 // 34996,
-			Watchers           int `json:"watchers"`              // 3513,
-			CommitsLast3Months int `json:"commits_last_3_months"` // 342,
-			CommitsLast1Year   int `json:"commits_last_1_year"`   // 1775,
-		} `json:"developer_activity"`
-		AllTimeHigh struct {
-			Price       float64 `json:"price"`        // 20089,
-			At          string  `json:"at"`           // "2018-06-02T22:51:28.209Z",
-			DaysSince   int     `json:"days_since"`   // 344,
-			PercentDown float64 `json:"percent_down"` // 81.47285775644839
-		} `json:"all_time_high"`
-	} `json:"metrics"`
-}
-
 type ErrorResponse struct {
 	Status struct {
 		Timestamp    string `json:"timestamp"`     // Current ISO 8601 timestamp on the server.
@@ -0,0 +1,472 @@
+package messari
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anrid/traderbot/pkg/jsoncache"
+	"github.com/anrid/traderbot/pkg/timeseries"
+	"github.com/stretchr/testify/require"
+)
+
+func newStubServer(t *testing.T, routes map[string]string) (*Messari, *httptest.Server) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, found := routes[r.URL.Path]
+		if !found {
+			w.Header().Set("content-type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"status":{"error_code":404,"error_message":"Not Found"}}`))
+			return
+		}
+		w.Header().Set("content-type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+
+	m := New("token")
+	m.baseURI = srv.URL
+	return m, srv
+}
+
+func TestAssetTimeSeries(t *testing.T) {
+	r := require.New(t)
+
+	m, _ := newStubServer(t, map[string]string{
+		"/v1/assets/terra-luna/metrics/act.addr.cnt/time-series": `{
+			"data": {
+				"schema": {"metric_id": "act.addr.cnt", "name": "Active Addresses"},
+				"parameters": {
+					"asset_key": "terra-luna",
+					"interval": "1d",
+					"columns": ["timestamp", "active_addresses"]
+				},
+				"values": [
+					[1646179200000, 35000],
+					[1646092800000, 34000],
+					[1646265600000, null]
+				]
+			}
+		}`,
+	})
+
+	ts, err := m.AssetTimeSeries("terra-luna", "act.addr.cnt", "2022-03-01", "2022-03-03", "1d")
+	r.NoError(err)
+	r.Equal("Active Addresses", ts.Schema.Name)
+
+	s, err := ts.Series("active_addresses")
+	r.NoError(err)
+	r.Equal(timeseries.Series{
+		{TS: 1646092800000, V: 34000},
+		{TS: 1646179200000, V: 35000},
+	}, s)
+
+	_, err = ts.Series("close")
+	r.EqualError(err, "time-series `act.addr.cnt` has no column `close`, expected one of: timestamp, active_addresses")
+
+	_, err = m.AssetTimeSeries("does-not-exist", "price", "2022-03-01", "2022-03-03", "1d")
+	r.Error(err)
+}
+
+func TestAssetMetricsAndProfile(t *testing.T) {
+	r := require.New(t)
+
+	m, _ := newStubServer(t, map[string]string{
+		"/v1/assets/bitcoin/metrics": `{
+			"data": {
+				"id": "1e31218a-e44e-4285-820c-8282ee222035",
+				"symbol": "BTC",
+				"name": "Bitcoin",
+				"slug": "bitcoin",
+				"market_data": {"price_usd": 38000.5},
+				"supply": {"circulating": 18970000, "annual_inflation_percent": 1.76}
+			}
+		}`,
+		"/v2/assets/bitcoin/profile": `{
+			"data": {
+				"symbol": "BTC",
+				"profile": {
+					"general": {"overview": {"category": "Payments", "official_links": [{"name": "Website", "link": "https://bitcoin.org"}]}},
+					"economics": {"consensus_and_emission": {"supply": {"is_capped_supply": true, "max_supply": 21000000}}}
+				}
+			}
+		}`,
+	})
+
+	am, err := m.AssetMetrics("bitcoin")
+	r.NoError(err)
+	r.Equal("BTC", am.Symbol)
+	r.Equal(38000.5, am.MarketData.PriceUSD)
+	r.Equal(1.76, am.Supply.AnnualInflationPct)
+
+	p, err := m.AssetProfile("bitcoin")
+	r.NoError(err)
+	r.Equal("Payments", p.Profile.General.Overview.Category)
+	r.Equal("https://bitcoin.org", p.Profile.General.Overview.OfficialLinks[0].Link)
+	r.True(p.Profile.Economics.ConsensusAndEmission.Supply.IsCappedSupply)
+	r.Equal(21000000.0, p.Profile.Economics.ConsensusAndEmission.Supply.MaxSupply)
+}
+
+type stubResponse struct {
+	status  int
+	headers map[string]string
+	body    string
+}
+
+// newAssetsServer serves `total` assets, `limit` per page, after first
+// replying with the given error responses.
+func newAssetsServer(t *testing.T, total int, errs ...stubResponse) (*Messari, *[]string, *[]time.Duration) {
+	var requests []string
+	var sleeps []time.Duration
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Query().Get("page"))
+
+		if len(errs) > 0 {
+			e := errs[0]
+			errs = errs[1:]
+			for k, v := range e.headers {
+				w.Header().Set(k, v)
+			}
+			w.WriteHeader(e.status)
+			w.Write([]byte(e.body))
+			return
+		}
+
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+		w.Header().Set("content-type", "application/json")
+
+		from := (page - 1) * limit
+		if from >= total {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"status":{"error_code":404,"error_message":"Not Found"}}`))
+			return
+		}
+
+		var data []string
+		for i := from; i < from+limit && i < total; i++ {
+			data = append(data, fmt.Sprintf(`{"symbol":"A%d"}`, i))
+		}
+		fmt.Fprintf(w, `{"data":[%s]}`, strings.Join(data, ","))
+	}))
+	t.Cleanup(srv.Close)
+
+	m := New("token")
+	m.baseURI = srv.URL
+	m.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+
+	return m, &requests, &sleeps
+}
+
+func symbols(as []*Asset) (s []string) {
+	for _, a := range as {
+		s = append(s, a.Symbol)
+	}
+	return
+}
+
+func TestAssetsPagination(t *testing.T) {
+	r := require.New(t)
+
+	// Last page is short.
+	m, requests, _ := newAssetsServer(t, 5)
+	as, err := m.AssetsWithOptions(AssetsOptions{PerPage: 2})
+	r.NoError(err)
+	r.Equal([]string{"A0", "A1", "A2", "A3", "A4"}, symbols(as))
+	r.Equal([]string{"1", "2", "3"}, *requests)
+
+	// Last page is full, so we learn about the end from a 404.
+	m, requests, _ = newAssetsServer(t, 4)
+	as, err = m.AssetsWithOptions(AssetsOptions{PerPage: 2})
+	r.NoError(err)
+	r.Len(as, 4)
+	r.Equal([]string{"1", "2", "3"}, *requests)
+
+	// A 404 on the first page is an error, not an empty result.
+	m, _, _ = newAssetsServer(t, 0)
+	_, err = m.AssetsWithOptions(AssetsOptions{PerPage: 2})
+	r.EqualError(err, "could not fetch assets page 1: got HTTP error code: 404: Not Found")
+
+	// Max pages.
+	m, requests, _ = newAssetsServer(t, 10)
+	as, err = m.AssetsWithOptions(AssetsOptions{PerPage: 2, MaxPages: 2})
+	r.NoError(err)
+	r.Equal([]string{"A0", "A1", "A2", "A3"}, symbols(as))
+	r.Equal([]string{"1", "2"}, *requests)
+}
+
+func TestAssetsResume(t *testing.T) {
+	r := require.New(t)
+
+	m, requests, _ := newAssetsServer(t, 5)
+
+	var saved []AssetsProgress
+	stopAfter := 2
+
+	o := AssetsOptions{
+		PerPage: 2,
+		OnPage: func(p *AssetsProgress) error {
+			saved = append(saved, *p)
+			if len(saved) == stopAfter {
+				return fmt.Errorf("interrupted")
+			}
+			return nil
+		},
+	}
+
+	_, err := m.AssetsWithOptions(o)
+	r.EqualError(err, "stopped fetching assets after page 2: interrupted")
+
+	progress := saved[len(saved)-1]
+	r.Equal(3, progress.NextPage)
+	r.False(progress.Done)
+	r.Len(progress.Assets, 4)
+
+	// Pick up where we left off.
+	stopAfter = -1
+	o.Progress = &progress
+	as, err := m.AssetsWithOptions(o)
+	r.NoError(err)
+	r.Equal([]string{"A0", "A1", "A2", "A3", "A4"}, symbols(as))
+	r.Equal([]string{"1", "2", "3"}, *requests)
+	r.True(saved[len(saved)-1].Done)
+}
+
+func TestAssetsWithCache(t *testing.T) {
+	r := require.New(t)
+
+	defer func(dir string) { jsoncache.Dir = dir }(jsoncache.Dir)
+	jsoncache.Dir = t.TempDir()
+
+	key := "messari/test-assets"
+	i := jsoncache.InvalidateDaily
+
+	m, requests, _ := newAssetsServer(t, 5)
+
+	o := AssetsOptions{
+		PerPage: 2,
+		OnPage: func(p *AssetsProgress) error {
+			if p.NextPage == 3 {
+				return fmt.Errorf("interrupted")
+			}
+			return nil
+		},
+	}
+
+	_, err := m.assetsWithCache(key, i, o)
+	r.EqualError(err, "stopped fetching assets after page 2: interrupted")
+
+	// Only the cursor and the pages themselves are persisted.
+	var progress AssetsProgress
+	r.NoError(jsoncache.Get(key+"-progress", &progress, i))
+	r.Equal(AssetsProgress{NextPage: 3}, progress)
+
+	var page []*Asset
+	r.NoError(jsoncache.Get(key+"-page-2", &page, i))
+	r.Equal([]string{"A2", "A3"}, symbols(page))
+
+	// Pick up where we left off.
+	o.OnPage = nil
+	as, err := m.assetsWithCache(key, i, o)
+	r.NoError(err)
+	r.Equal([]string{"A0", "A1", "A2", "A3", "A4"}, symbols(as))
+	r.Equal([]string{"1", "2", "3"}, *requests)
+
+	// The progress is removed once all assets are cached.
+	r.Equal(jsoncache.ErrNotFound, jsoncache.Get(key+"-progress", &progress, i))
+	for _, k := range []string{"-page-1", "-page-2", "-page-3"} {
+		r.Equal(jsoncache.ErrNotFound, jsoncache.Get(key+k, &page, i))
+	}
+
+	as, err = m.assetsWithCache(key, i, o)
+	r.NoError(err)
+	r.Len(as, 5)
+	r.Len(*requests, 3)
+}
+
+func TestAssetsRateLimit(t *testing.T) {
+	r := require.New(t)
+
+	rateLimited := stubResponse{
+		status:  http.StatusTooManyRequests,
+		headers: map[string]string{"content-type": "application/json", "Retry-After": "7"},
+		body:    `{"status":{"error_code":429,"error_message":"Rate limit exceeded"}}`,
+	}
+	badGateway := stubResponse{
+		status:  http.StatusBadGateway,
+		headers: map[string]string{"content-type": "text/html"},
+		body:    "<html><body>502 Bad Gateway</body></html>",
+	}
+
+	// Retry-After is respected, otherwise we back off exponentially.
+	m, requests, sleeps := newAssetsServer(t, 1, rateLimited, badGateway, badGateway)
+	as, err := m.AssetsWithOptions(AssetsOptions{PerPage: 2})
+	r.NoError(err)
+	r.Len(as, 1)
+	r.Equal([]string{"1", "1", "1", "1"}, *requests)
+	r.Equal([]time.Duration{7 * time.Second, 4 * time.Second, 8 * time.Second}, *sleeps)
+
+	// Exponential backoff is capped, but a longer Retry-After is an error.
+	m, requests, sleeps = newAssetsServer(t, 1, badGateway, badGateway, badGateway)
+	m.MaxBackoff = 3 * time.Second
+	_, err = m.AssetsWithOptions(AssetsOptions{PerPage: 2})
+	r.NoError(err)
+	r.Len(*requests, 4)
+	r.Equal([]time.Duration{2 * time.Second, 3 * time.Second, 3 * time.Second}, *sleeps)
+
+	m, requests, sleeps = newAssetsServer(t, 1, rateLimited)
+	m.MaxBackoff = 5 * time.Second
+	_, err = m.AssetsWithOptions(AssetsOptions{PerPage: 2})
+	r.EqualError(err, "could not fetch assets page 1: API asked us to wait 7s, longer than max backoff 5s: got HTTP error code: 429: Rate limit exceeded")
+	r.Len(*requests, 1)
+	r.Empty(*sleeps)
+
+	// Retries are bounded and non-JSON error bodies are reported.
+	m, requests, _ = newAssetsServer(t, 1, badGateway, badGateway, badGateway)
+	m.MaxRetries = 2
+	_, err = m.AssetsWithOptions(AssetsOptions{PerPage: 2})
+	r.EqualError(err, "could not fetch assets page 1: giving up after 2 retries: got HTTP error code: 502: <html><body>502 Bad Gateway</body></html>")
+	r.Len(*requests, 3)
+
+	// Client errors aren't retried.
+	m, requests, _ = newAssetsServer(t, 1, stubResponse{
+		status:  http.StatusUnauthorized,
+		headers: map[string]string{"content-type": "application/json"},
+		body:    `{"status":{"error_code":401,"error_message":"Invalid API key"}}`,
+	})
+	_, err = m.AssetsWithOptions(AssetsOptions{PerPage: 2})
+	r.EqualError(err, "could not fetch assets page 1: got HTTP error code: 401: Invalid API key")
+	r.Len(*requests, 1)
+}
+
+func TestRetryAfter(t *testing.T) {
+	r := require.New(t)
+
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	h := http.Header{}
+	r.Equal(time.Duration(0), retryAfter(h, now))
+
+	h.Set("x-ratelimit-reset", "30")
+	r.Equal(30*time.Second, retryAfter(h, now))
+
+	h.Set("x-ratelimit-reset", fmt.Sprintf("%d", now.Add(90*time.Second).Unix()))
+	r.Equal(90*time.Second, retryAfter(h, now))
+
+	h.Set("Retry-After", now.Add(time.Minute).Format(http.TimeFormat))
+	r.Equal(time.Minute, retryAfter(h, now))
+
+	h.Set("Retry-After", "5")
+	r.Equal(5*time.Second, retryAfter(h, now))
+}
+
+func TestAssetFieldsAndValue(t *testing.T) {
+	r := require.New(t)
+
+	var fields string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fields = req.URL.Query().Get("fields")
+		w.Header().Set("content-type", "application/json")
+		w.Write([]byte(`{"data":[{
+			"symbol": "BTC",
+			"metrics": {
+				"supply": {"circulating": 18970000, "stock_to_flow": 56.2},
+				"developer_activity": {"stars": 34996},
+				"mining_stats": {"hash_rate": 190.5, "mining_algo": "SHA-256"},
+				"roi_data": {"percent_change_last_1_year": null}
+			}
+		}]}`))
+	}))
+	defer srv.Close()
+
+	m := New("token")
+	m.baseURI = srv.URL
+
+	as, err := m.AssetsWithOptions(AssetsOptions{
+		Fields:  []string{"symbol", "metrics/supply", "metrics/developer_activity", "metrics/mining_stats"},
+		PerPage: 10,
+	})
+	r.NoError(err)
+	r.Equal("symbol,metrics/supply,metrics/developer_activity,metrics/mining_stats", fields)
+	r.Len(as, 1)
+
+	a := as[0]
+	r.Equal(56.2, a.Metrics.Supply.StockToFlow)
+	r.Equal(34996, a.Metrics.DeveloperActivity.Stars)
+
+	// Unmodeled fields are reachable by path.
+	f, found := a.Float("metrics/mining_stats/hash_rate")
+	r.True(found)
+	r.Equal(190.5, f)
+
+	f, found = a.Float("metrics.supply.circulating")
+	r.True(found)
+	r.Equal(18970000.0, f)
+
+	v, found := a.Value("metrics.mining_stats.mining_algo")
+	r.True(found)
+	r.Equal("SHA-256", v)
+
+	_, found = a.Float("metrics.mining_stats.mining_algo") // Not a number.
+	r.False(found)
+	_, found = a.Float("metrics.roi_data.percent_change_last_1_year") // null
+	r.False(found)
+	_, found = a.Float("metrics.does_not_exist.value")
+	r.False(found)
+
+	// Unmodeled fields survive a round trip through JSON, e.g. the cache.
+	b, err := json.Marshal(as)
+	r.NoError(err)
+
+	var cached []*Asset
+	r.NoError(json.Unmarshal(b, &cached))
+	f, found = cached[0].Float("metrics.mining_stats.hash_rate")
+	r.True(found)
+	r.Equal(190.5, f)
+
+	// Changes to modeled fields are merged into the unmodeled ones, without
+	// adding modeled fields that weren't fetched.
+	cached[0].Metrics.Supply.StockToFlow = 60
+	cached[0].Metrics.DeveloperActivity.Stars = 0
+	b, err = json.Marshal(cached[0])
+	r.NoError(err)
+
+	a = new(Asset)
+	r.NoError(json.Unmarshal(b, a))
+	r.Equal(60.0, a.Metrics.Supply.StockToFlow)
+	r.Equal(0, a.Metrics.DeveloperActivity.Stars)
+	f, found = a.Float("metrics.developer_activity.stars")
+	r.True(found)
+	r.Equal(0.0, f)
+	f, found = a.Float("metrics.mining_stats.hash_rate")
+	r.True(found)
+	r.Equal(190.5, f)
+	_, found = a.Float("metrics.supply.annual_inflation_percent")
+	r.False(found)
+	_, found = a.Value("metrics.market_data")
+	r.False(found)
+
+	// Value sees changes without a round trip.
+	a.Metrics.Supply.Circulating = 19000000
+	f, found = a.Float("metrics.supply.circulating")
+	r.True(found)
+	r.Equal(19000000.0, f)
+	v, found = a.Value("metrics/supply")
+	r.True(found)
+	r.Equal(map[string]interface{}{"circulating": 19000000.0, "stock_to_flow": 60.0}, v)
+
+	// Assets that weren't decoded from JSON fall back to modeled fields.
+	a = &Asset{Symbol: "LUNA"}
+	a.Metrics.Supply.AnnualInflationPct = 4.2
+	f, found = a.Float("metrics.supply.annual_inflation_percent")
+	r.True(found)
+	r.Equal(4.2, f)
+}
@@ -0,0 +1,203 @@
+package messari
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/anrid/traderbot/pkg/jsoncache"
+	"github.com/anrid/traderbot/pkg/timeseries"
+	"github.com/pkg/errors"
+)
+
+// AssetMetrics returns the latest metrics for an asset, where key is the
+// asset's ID, slug or symbol.
+func (cg *Messari) AssetMetrics(key string) (*AssetMetrics, error) {
+	url := "/v1/assets/" + url.PathEscape(key) + "/metrics"
+	// https://data.messari.io/api/v1/assets/bitcoin/metrics
+
+	resp := struct {
+		Data *AssetMetrics `json:"data"`
+	}{}
+
+	err := cg.getJSONWithRetry(cg.baseURI+url, nil, &resp)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not fetch metrics for asset `%s`", key)
+	}
+	if resp.Data == nil {
+		return nil, errors.Errorf("got no metrics for asset `%s`", key)
+	}
+
+	return resp.Data, nil
+}
+
+// AssetProfile returns qualitative information about an asset, e.g. its
+// category, token economics and supply curve.
+func (cg *Messari) AssetProfile(key string) (*AssetProfile, error) {
+	url := "/v2/assets/" + url.PathEscape(key) + "/profile"
+	// https://data.messari.io/api/v2/assets/bitcoin/profile
+
+	resp := struct {
+		Data *AssetProfile `json:"data"`
+	}{}
+
+	err := cg.getJSONWithRetry(cg.baseURI+url, nil, &resp)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not fetch profile for asset `%s`", key)
+	}
+	if resp.Data == nil {
+		return nil, errors.Errorf("got no profile for asset `%s`", key)
+	}
+
+	return resp.Data, nil
+}
+
+// AssetTimeSeries returns the values of a metric, e.g. "price",
+// "act.addr.cnt" (active addresses), "real.vol" or "sply.circ", between
+// the given dates (format YYYY-MM-DD) at the given interval, e.g. "1d" or
+// "1w". Use TimeSeries.Series to pick out a single column.
+func (cg *Messari) AssetTimeSeries(key, metric, start, end, interval string) (*TimeSeries, error) {
+	u := url.URL{}
+	q := u.Query()
+	q.Add("start", start)
+	q.Add("end", end)
+	q.Add("interval", interval)
+	q.Add("order", "ascending")
+	q.Add("timestamp-format", "unix-milliseconds")
+	u.RawQuery = q.Encode()
+
+	url := "/v1/assets/" + url.PathEscape(key) + "/metrics/" + url.PathEscape(metric) + "/time-series?" + u.RawQuery
+	// https://data.messari.io/api/v1/assets/bitcoin/metrics/price/time-series?start=2021-01-01&end=2021-02-01&interval=1d
+
+	resp := struct {
+		Data *TimeSeries `json:"data"`
+	}{}
+
+	err := cg.getJSONWithRetry(cg.baseURI+url, nil, &resp)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not fetch time-series `%s` for asset `%s`", metric, key)
+	}
+	if resp.Data == nil {
+		return nil, errors.Errorf("got no time-series `%s` for asset `%s`", metric, key)
+	}
+
+	return resp.Data, nil
+}
+
+func (cg *Messari) AssetTimeSeriesWithCache(key, metric, start, end, interval string, i jsoncache.InvalidateCachePeriod) (ts *TimeSeries, err error) {
+	cacheKey := fmt.Sprintf("messari/%s-%s-%s-%s-%s", key, metric, start, end, interval)
+
+	_, err = jsoncache.GetOrFetch(cacheKey, i, &ts, func() (interface{}, error) {
+		return cg.AssetTimeSeries(key, metric, start, end, interval)
+	})
+	return
+}
+
+type AssetMetrics struct {
+	ID     string `json:"id"`     // "1e31218a-e44e-4285-820c-8282ee222035",
+	Symbol string `json:"symbol"` // "BTC",
+	Name   string `json:"name"`   // "Bitcoin",
+	Slug   string `json:"slug"`   // "bitcoin",
+	Metrics
+}
+
+type AssetProfile struct {
+	ID      string `json:"id"`     // "1e31218a-e44e-4285-820c-8282ee222035",
+	Symbol  string `json:"symbol"` // "BTC",
+	Name    string `json:"name"`   // "Bitcoin",
+	Slug    string `json:"slug"`   // "bitcoin",
+	Profile struct {
+		General struct {
+			Overview struct {
+				IsVerified     bool   `json:"is_verified"`     // true
+				Tagline        string `json:"tagline"`         // "A peer-to-peer electronic cash system"
+				Category       string `json:"category"`        // "Payments"
+				Sector         string `json:"sector"`          // "Currencies"
+				Tags           string `json:"tags"`            // "Medium of Exchange"
+				ProjectDetails string `json:"project_details"` // "Bitcoin is a decentralized ..."
+				OfficialLinks  []struct {
+					Name string `json:"name"` // "Website"
+					Link string `json:"link"` // "https://bitcoin.org"
+				} `json:"official_links"`
+			} `json:"overview"`
+		} `json:"general"`
+		Economics struct {
+			Token struct {
+				TokenName       string  `json:"token_name"`     // "Bitcoin"
+				TokenType       string  `json:"token_type"`     // "Native"
+				BlockReward     float64 `json:"block_reward"`   // 6.25
+				LaunchStyle     string  `json:"launch_style"`   // "Fair Launch"
+				InitialSupply   float64 `json:"initial_supply"` // 0
+				TokenUsage      string  `json:"token_usage"`    // "Payments"
+				TokenUsageNotes string  `json:"token_usage_details"`
+			} `json:"token"`
+			ConsensusAndEmission struct {
+				Supply struct {
+					SupplyCurveDetails  string  `json:"supply_curve_details"`  // "Block rewards halve every 210,000 blocks ..."
+					GeneralEmissionType string  `json:"general_emission_type"` // "Deflationary"
+					PreciseEmissionType string  `json:"precise_emission_type"` // "Halving"
+					IsCappedSupply      bool    `json:"is_capped_supply"`      // true
+					MaxSupply           float64 `json:"max_supply"`            // 21000000
+				} `json:"supply"`
+			} `json:"consensus_and_emission"`
+		} `json:"economics"`
+	} `json:"profile"`
+}
+
+type TimeSeries struct {
+	Schema struct {
+		MetricID     string            `json:"metric_id"`     // "price"
+		Name         string            `json:"name"`          // "Price"
+		Description  string            `json:"description"`   // "Volume weighted average price computed using Messari Methodology"
+		ValuesSchema map[string]string `json:"values_schema"` // {"timestamp": "Time in milliseconds since the epoch ...", "close": "..."}
+	} `json:"schema"`
+	Parameters struct {
+		AssetKey string   `json:"asset_key"` // "bitcoin"
+		Start    string   `json:"start"`     // "2021-01-01T00:00:00Z"
+		End      string   `json:"end"`       // "2021-02-01T00:00:00Z"
+		Interval string   `json:"interval"`  // "1d"
+		Order    string   `json:"order"`     // "ascending"
+		Columns  []string `json:"columns"`   // ["timestamp", "open", "high", "low", "close", "volume"]
+	} `json:"parameters"`
+	Values [][]*float64 `json:"values"` // [[1609459200000, 28923.63, 29600.62, 28803.58, 29331.69, 1.33e+09], ...]
+}
+
+// Series returns the given column (e.g. "close" or "active_addresses")
+// as a time series in ascending order. Rows where the column is null are
+// skipped.
+func (ts *TimeSeries) Series(column string) (timeseries.Series, error) {
+	tsCol, valCol := -1, -1
+	for i, c := range ts.Parameters.Columns {
+		switch c {
+		case "timestamp":
+			tsCol = i
+		case column:
+			valCol = i
+		}
+	}
+	if tsCol == -1 {
+		return nil, errors.Errorf("time-series `%s` has no timestamp column", ts.Schema.MetricID)
+	}
+	if valCol == -1 {
+		return nil, errors.Errorf("time-series `%s` has no column `%s`, expected one of: %s",
+			ts.Schema.MetricID, column, strings.Join(ts.Parameters.Columns, ", "))
+	}
+
+	var s timeseries.Series
+	for _, row := range ts.Values {
+		if len(row) <= tsCol || len(row) <= valCol || row[tsCol] == nil || row[valCol] == nil {
+			continue
+		}
+		s = append(s, timeseries.ValueAt{
+			TS: int64(*row[tsCol]),
+			V:  *row[valCol],
+		})
+	}
+
+	sort.SliceStable(s, func(i, j int) bool {
+		return s[i].TS < s[j].TS
+	})
+
+	return s, nil
+}
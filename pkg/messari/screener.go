@@ -0,0 +1,382 @@
+package messari
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// ScreenerFields are the short names that can be used in screener
+// filters and sort keys. Any other field can be referenced by its path,
+// e.g. "metrics.marketcap.rank" (see Asset.Value).
+var ScreenerFields = map[string]string{
+	"price":        "Price in USD",
+	"inflation":    "Annual inflation in percent",
+	"y10":          "Percent of the Y+10 supply issued today",
+	"y2050":        "Percent of the Y2050 supply issued today",
+	"s2f":          "Stock-to-flow ratio",
+	"mcap":         "Market cap in USD (circulating supply x price)",
+	"ath_drawdown": "Percent down from all-time high",
+	"dilution":     "Supply growth from today to Y+10 in percent",
+}
+
+// ScreenerValue returns the value of a screener field or path for the
+// given asset. found is false if the asset has no data for it.
+func ScreenerValue(a *Asset, field string) (v float64, found bool) {
+	switch field {
+	case "price":
+		return a.Float("metrics.market_data.price_usd")
+	case "inflation":
+		return a.Float("metrics.supply.annual_inflation_percent")
+	case "y10":
+		return a.Float("metrics.supply.y_plus10_issued_percent")
+	case "y2050":
+		return a.Float("metrics.supply.y_2050_issued_percent")
+	case "s2f":
+		return a.Float("metrics.supply.stock_to_flow")
+	case "mcap":
+		circulating, found := a.Float("metrics.supply.circulating")
+		price, found2 := a.Float("metrics.market_data.price_usd")
+		if mc := circulating * price; found && found2 && mc > 0 {
+			return mc, true
+		}
+		return a.Float("metrics.marketcap.current_marketcap_usd")
+	case "ath_drawdown":
+		return a.Float("metrics.all_time_high.percent_down")
+	case "dilution":
+		circulating, found := a.Float("metrics.supply.circulating")
+		y10, found2 := a.Float("metrics.supply.y_plus10")
+		if !found || !found2 || circulating <= 0 {
+			return 0, false
+		}
+		return (y10/circulating - 1) * 100, true
+	}
+
+	return a.Float(field)
+}
+
+// Filter matches assets against an expression such as
+// `inflation > 5 && mcap >= 100m || s2f > 50`. Comparisons are joined by
+// `&&` (or `and`) and `||` (or `or`), where `&&` binds tighter. Numbers may
+// have a k, m or b suffix. Assets lacking data for a field never match a
+// comparison on it.
+type Filter struct {
+	Expr string
+	any  [][]comparison // OR of ANDs
+}
+
+type comparison struct {
+	field string
+	op    string
+	value float64
+}
+
+func (c comparison) match(v float64, found bool) bool {
+	if !found {
+		return false
+	}
+
+	switch c.op {
+	case ">":
+		return v > c.value
+	case ">=":
+		return v >= c.value
+	case "<":
+		return v < c.value
+	case "<=":
+		return v <= c.value
+	case "==":
+		return v == c.value
+	case "!=":
+		return v != c.value
+	}
+	return false
+}
+
+func (f *Filter) Match(a *Asset) bool {
+	return f.match(func(field string) (float64, bool) {
+		return ScreenerValue(a, field)
+	})
+}
+
+// match is Match with the screener values looked up by value.
+func (f *Filter) match(value func(field string) (float64, bool)) bool {
+	if f == nil || len(f.any) == 0 {
+		return true
+	}
+
+	for _, all := range f.any {
+		matched := true
+		for _, c := range all {
+			if !c.match(value(c.field)) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+type token struct {
+	text string
+	pos  int // Position in the expression, starting at 1
+}
+
+func tokenize(expr string) (ts []token, err error) {
+	rs := []rune(expr)
+
+	isIdent := func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || r == '/' || r == '-' || r == '+'
+	}
+
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case strings.ContainsRune("<>=!&|", r):
+			j := i
+			for j < len(rs) && strings.ContainsRune("<>=!&|", rs[j]) {
+				j++
+			}
+			ts = append(ts, token{string(rs[i:j]), i + 1})
+			i = j
+		case isIdent(r):
+			j := i
+			for j < len(rs) && isIdent(rs[j]) {
+				j++
+			}
+			ts = append(ts, token{string(rs[i:j]), i + 1})
+			i = j
+		default:
+			return nil, errors.Errorf("unexpected character `%c` at position %d", r, i+1)
+		}
+	}
+	return
+}
+
+// ParseFilter parses a filter expression, see Filter. An empty expression
+// matches all assets.
+func ParseFilter(expr string) (*Filter, error) {
+	f := &Filter{Expr: expr}
+
+	ts, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(ts) == 0 {
+		return f, nil
+	}
+
+	var all []comparison
+	for i := 0; ; {
+		if i+3 > len(ts) {
+			end := len(expr) + 1
+			return nil, errors.Errorf("incomplete comparison at position %d, expected `<field> <op> <number>`", end)
+		}
+
+		fieldTok, opTok, valueTok := ts[i], ts[i+1], ts[i+2]
+
+		err = validateField(fieldTok.text)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid field `%s` at position %d", fieldTok.text, fieldTok.pos)
+		}
+
+		switch opTok.text {
+		case ">", ">=", "<", "<=", "==", "!=":
+		default:
+			return nil, errors.Errorf("invalid operator `%s` at position %d, expected one of >, >=, <, <=, ==, !=", opTok.text, opTok.pos)
+		}
+
+		value, err := parseNumber(valueTok.text)
+		if err != nil {
+			return nil, errors.Errorf("invalid number `%s` at position %d", valueTok.text, valueTok.pos)
+		}
+
+		all = append(all, comparison{fieldTok.text, opTok.text, value})
+		i += 3
+
+		if i == len(ts) {
+			break
+		}
+
+		switch strings.ToLower(ts[i].text) {
+		case "&&", "and":
+		case "||", "or":
+			f.any = append(f.any, all)
+			all = nil
+		default:
+			return nil, errors.Errorf("unexpected `%s` at position %d, expected `&&` or `||`", ts[i].text, ts[i].pos)
+		}
+		i++
+	}
+	f.any = append(f.any, all)
+
+	return f, nil
+}
+
+func validateField(field string) error {
+	if _, found := ScreenerFields[field]; found {
+		return nil
+	}
+	if strings.ContainsAny(field, "./") {
+		// A path to any field, which we can't validate without data.
+		return nil
+	}
+
+	var names []string
+	for name := range ScreenerFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return errors.Errorf("expected a path like `metrics.supply.liquid` or one of: %s", strings.Join(names, ", "))
+}
+
+func parseNumber(s string) (float64, error) {
+	mult := 1.0
+	switch strings.ToLower(s[len(s)-1:]) {
+	case "k":
+		mult = 1e3
+	case "m":
+		mult = 1e6
+	case "b":
+		mult = 1e9
+	}
+	if mult != 1.0 {
+		s = s[:len(s)-1]
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	return f * mult, nil
+}
+
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+func (k SortKey) String() string {
+	if k.Desc {
+		return "-" + k.Field
+	}
+	return k.Field
+}
+
+// ParseSort parses a comma separated list of fields to sort by, each
+// optionally prefixed with `-` for descending order, e.g. "-inflation,mcap".
+func ParseSort(s string) (keys []SortKey, err error) {
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+
+		k := SortKey{Field: f}
+		if strings.HasPrefix(f, "-") {
+			k = SortKey{Field: f[1:], Desc: true}
+		}
+
+		err = validateField(k.Field)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid sort field `%s`", k.Field)
+		}
+		keys = append(keys, k)
+	}
+	return
+}
+
+// Screen returns the assets matching the filter, sorted by the given
+// keys. Assets without data for a sort key are sorted last.
+func Screen(as []*Asset, f *Filter, keys []SortKey) []*Asset {
+	// Look up every field once per asset, as that's what's slow.
+	index := make(map[string]int)
+	addField := func(field string) {
+		if _, found := index[field]; !found {
+			index[field] = len(index)
+		}
+	}
+	if f != nil {
+		for _, all := range f.any {
+			for _, c := range all {
+				addField(c.field)
+			}
+		}
+	}
+	keyIndex := make([]int, len(keys))
+	for n, k := range keys {
+		addField(k.Field)
+		keyIndex[n] = index[k.Field]
+	}
+
+	type value struct {
+		v     float64
+		found bool
+	}
+	type screened struct {
+		a      *Asset
+		values []value
+	}
+
+	var out []screened
+	for _, a := range as {
+		s := screened{a, make([]value, len(index))}
+		for field, i := range index {
+			s.values[i].v, s.values[i].found = ScreenerValue(a, field)
+		}
+
+		matched := f.match(func(field string) (float64, bool) {
+			v := s.values[index[field]]
+			return v.v, v.found
+		})
+		if matched {
+			out = append(out, s)
+		}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		for n, k := range keys {
+			vi, vj := out[i].values[keyIndex[n]], out[j].values[keyIndex[n]]
+
+			if vi.found != vj.found {
+				return vi.found
+			}
+			if vi.v == vj.v {
+				continue
+			}
+			if k.Desc {
+				return vi.v > vj.v
+			}
+			return vi.v < vj.v
+		}
+		return false
+	})
+
+	var assets []*Asset
+	for _, s := range out {
+		assets = append(assets, s.a)
+	}
+	return assets
+}
+
+func (f *Filter) String() string {
+	var ors []string
+	for _, all := range f.any {
+		var ands []string
+		for _, c := range all {
+			ands = append(ands, fmt.Sprintf("%s %s %g", c.field, c.op, c.value))
+		}
+		ors = append(ors, strings.Join(ands, " && "))
+	}
+	return strings.Join(ors, " || ")
+}
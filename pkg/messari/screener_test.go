@@ -0,0 +1,116 @@
+package messari
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newScreenerAsset decodes an asset from JSON like the API returns it, so
+// that fields that aren't given are missing.
+func newScreenerAsset(symbol string, price, circulating, inflation, y10 float64) *Asset {
+	b, err := json.Marshal(map[string]interface{}{
+		"symbol": symbol,
+		"metrics": map[string]interface{}{
+			"market_data": map[string]interface{}{"price_usd": price},
+			"supply": map[string]interface{}{
+				"circulating":              circulating,
+				"annual_inflation_percent": inflation,
+				"y_plus10_issued_percent":  y10,
+			},
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	a := new(Asset)
+	if err = json.Unmarshal(b, a); err != nil {
+		panic(err)
+	}
+	return a
+}
+
+func TestParseFilter(t *testing.T) {
+	r := require.New(t)
+
+	f, err := ParseFilter("inflation > 5 && mcap >= 100m || y10<50 and s2f != 0")
+	r.NoError(err)
+	r.Equal("inflation > 5 && mcap >= 1e+08 || y10 < 50 && s2f != 0", f.String())
+
+	f, err = ParseFilter("  ")
+	r.NoError(err)
+	r.True(f.Match(&Asset{}))
+
+	for expr, msg := range map[string]string{
+		"inflation >> 5":               "invalid operator `>>` at position 11, expected one of >, >=, <, <=, ==, !=",
+		"inflation > 5 && mcap > lots": "invalid number `lots` at position 25",
+		"inflation > 5 &&":             "incomplete comparison at position 17, expected `<field> <op> <number>`",
+		"inflation > 5 mcap > 1":       "unexpected `mcap` at position 15, expected `&&` or `||`",
+		"inflation > (5)":              "unexpected character `(` at position 13",
+		"inflashun > 5":                "invalid field `inflashun` at position 1: expected a path like `metrics.supply.liquid` or one of: ath_drawdown, dilution, inflation, mcap, price, s2f, y10, y2050",
+	} {
+		_, err = ParseFilter(expr)
+		r.EqualError(err, msg, expr)
+	}
+}
+
+func TestScreen(t *testing.T) {
+	r := require.New(t)
+
+	btc := newScreenerAsset("BTC", 40_000, 19_000_000, 1.7, 90)
+	luna := newScreenerAsset("LUNA", 90, 380_000_000, 12.5, 0)
+	osmo := newScreenerAsset("OSMO", 8, 300_000_000, 45, 30)
+	dead := newScreenerAsset("DEAD", 0, 1_000_000, 100, 1) // No price, so no market cap.
+
+	as := []*Asset{btc, luna, osmo, dead}
+
+	f, err := ParseFilter("mcap > 1b && inflation > 5 || y10 > 80")
+	r.NoError(err)
+
+	keys, err := ParseSort("-inflation")
+	r.NoError(err)
+
+	r.Equal([]*Asset{osmo, luna, btc}, Screen(as, f, keys))
+
+	// Paths work in filters and assets lacking a field sort last.
+	osmo.Metrics.AllTimeHigh.Price = 11
+	osmo.Metrics.AllTimeHigh.PercentDown = 27
+	btc.Metrics.AllTimeHigh.Price = 69_000
+	btc.Metrics.AllTimeHigh.PercentDown = 42
+
+	f, err = ParseFilter("metrics.supply.circulating > 10m")
+	r.NoError(err)
+
+	keys, err = ParseSort("ath_drawdown, -mcap")
+	r.NoError(err)
+
+	r.Equal([]*Asset{osmo, btc, luna}, Screen(as, f, keys))
+
+	_, err = ParseSort("-infl")
+	r.Error(err)
+
+	// Zero values the API returned are data, missing fields aren't.
+	luna.Metrics.Supply.AnnualInflationPct = 0
+	v, found := ScreenerValue(luna, "inflation")
+	r.True(found)
+	r.Equal(0.0, v)
+
+	for _, field := range []string{"y2050", "s2f", "ath_drawdown"} {
+		_, found = ScreenerValue(luna, field)
+		r.False(found, field)
+	}
+
+	_, found = ScreenerValue(dead, "mcap")
+	r.False(found)
+
+	v, found = ScreenerValue(osmo, "dilution")
+	r.False(found)
+	r.Equal(0.0, v)
+
+	osmo.Metrics.Supply.YPlus10 = 600_000_000
+	v, found = ScreenerValue(osmo, "dilution")
+	r.True(found)
+	r.Equal(100.0, v)
+}
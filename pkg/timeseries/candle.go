@@ -0,0 +1,111 @@
+package timeseries
+
+import (
+	"math"
+)
+
+// Candle is an OHLC price bar starting at TS.
+type Candle struct {
+	TS    int64   `json:"ts"`
+	Open  float64 `json:"o"`
+	High  float64 `json:"h"`
+	Low   float64 `json:"l"`
+	Close float64 `json:"c"`
+}
+
+func (c Candle) Date() string {
+	return FromTSToDate(c.TS)
+}
+
+type Candles []Candle
+
+// CandlesFromSeries approximates daily candles from closing prices when
+// no OHLC data is available: each candle opens at the previous close and
+// its high and low are the higher and lower of the two closes. Ranges are
+// therefore close-to-close and never wider than the true high-low range.
+// The first candle has no range.
+func CandlesFromSeries(ts Series) (cs Candles) {
+	for i, t := range ts {
+		open := t.V
+		if i > 0 {
+			open = ts[i-1].V
+		}
+		cs = append(cs, Candle{
+			TS:    t.TS,
+			Open:  open,
+			High:  math.Max(open, t.V),
+			Low:   math.Min(open, t.V),
+			Close: t.V,
+		})
+	}
+	return
+}
+
+// FromOHLCTuples converts tuples in the form [ts, open, high, low, close],
+// as returned by CoinGecko, to candles.
+func FromOHLCTuples(tuples [][]float64) (cs Candles) {
+	for _, t := range tuples {
+		if len(t) < 5 {
+			continue
+		}
+		cs = append(cs, Candle{TS: int64(t[0]), Open: t[1], High: t[2], Low: t[3], Close: t[4]})
+	}
+	return
+}
+
+// Daily merges intraday candles into one candle per date (local time).
+// Candles must be in ascending order.
+func (cs Candles) Daily() (daily Candles) {
+	for _, c := range cs {
+		if n := len(daily); n > 0 && daily[n-1].Date() == c.Date() {
+			d := &daily[n-1]
+			d.High = math.Max(d.High, c.High)
+			d.Low = math.Min(d.Low, c.Low)
+			d.Close = c.Close
+			continue
+		}
+		daily = append(daily, c)
+	}
+	return
+}
+
+// Resample merges intraday candles into one candle per entry of a series
+// of closing prices, e.g. daily market prices, so that candle-based
+// indicators line up with them. Candles are timestamped at their close, as
+// returned by CoinGecko, and belong to the first entry at or after it. The
+// close of a merged candle is that of the series. Entries without candles,
+// such as the first, are approximated as in CandlesFromSeries. Both must be
+// in ascending order.
+func (cs Candles) Resample(ts Series) (out Candles) {
+	j := 0
+	for i, t := range ts {
+		c := Candle{TS: t.TS, Open: t.V, High: t.V, Low: t.V, Close: t.V}
+		if i > 0 {
+			c.Open = ts[i-1].V
+			c.High = math.Max(c.Open, t.V)
+			c.Low = math.Min(c.Open, t.V)
+
+			for j < len(cs) && cs[j].TS <= ts[i-1].TS {
+				j++
+			}
+			for merged := false; j < len(cs) && cs[j].TS <= t.TS; j++ {
+				if !merged {
+					c.Open, c.High, c.Low = cs[j].Open, t.V, t.V
+					merged = true
+				}
+				c.High = math.Max(c.High, cs[j].High)
+				c.Low = math.Min(c.Low, cs[j].Low)
+			}
+		}
+		out = append(out, c)
+	}
+	return
+}
+
+// Closes returns the closing prices as a series.
+func (cs Candles) Closes() (ts Series) {
+	for _, c := range cs {
+		ts = append(ts, ValueAt{TS: c.TS, V: c.Close})
+	}
+	return
+}
@@ -73,6 +73,38 @@ func FromTuples(tuples [][]interface{}) Series {
 	return ts
 }
 
+// Align returns the values of a and b on the dates found in both series,
+// e.g. to join CoinGecko prices with a Messari metric. If a series has
+// several values on the same date, the most recent one is used.
+func Align(a, b Series) (alignedA, alignedB Series) {
+	byDate := func(ts Series) map[string]ValueAt {
+		m := make(map[string]ValueAt)
+		for _, t := range ts {
+			m[t.Date()] = t
+		}
+		return m
+	}
+
+	as := byDate(a)
+	bs := byDate(b)
+
+	seen := make(map[string]bool)
+	for _, t := range a {
+		d := t.Date()
+		if seen[d] {
+			continue
+		}
+		seen[d] = true
+
+		if vb, found := bs[d]; found {
+			alignedA = append(alignedA, as[d])
+			alignedB = append(alignedB, vb)
+		}
+	}
+
+	return
+}
+
 func DiffDays(dateA, dateB string) (days int) {
 	a, err1 := time.Parse(dateFormat, dateA)
 	b, err2 := time.Parse(dateFormat, dateB)
@@ -0,0 +1,84 @@
+package timeseries
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlign(t *testing.T) {
+	r := require.New(t)
+
+	day := func(d int, hour int) int64 {
+		return time.Date(2022, 3, d, hour, 0, 0, 0, time.Local).UnixMilli()
+	}
+
+	prices := Series{
+		{day(1, 0), 1.0},
+		{day(2, 0), 2.0},
+		{day(3, 0), 3.0},
+		{day(3, 12), 3.5}, // Latest value for the day wins.
+		{day(4, 0), 4.0},
+	}
+
+	activeAddresses := Series{
+		{day(2, 0), 200},
+		{day(3, 0), 300},
+		{day(5, 0), 500},
+	}
+
+	a, b := Align(prices, activeAddresses)
+
+	r.Equal(Series{{day(2, 0), 2.0}, {day(3, 12), 3.5}}, a)
+	r.Equal(Series{{day(2, 0), 200}, {day(3, 0), 300}}, b)
+}
+
+func TestCandles(t *testing.T) {
+	r := require.New(t)
+
+	day := func(d int, hour int) int64 {
+		return time.Date(2022, 3, d, hour, 0, 0, 0, time.Local).UnixMilli()
+	}
+
+	cs := CandlesFromSeries(Series{
+		{day(1, 0), 2.0},
+		{day(2, 0), 3.0},
+		{day(3, 0), 1.0},
+	})
+
+	r.Equal(Candles{
+		{TS: day(1, 0), Open: 2, High: 2, Low: 2, Close: 2},
+		{TS: day(2, 0), Open: 2, High: 3, Low: 2, Close: 3},
+		{TS: day(3, 0), Open: 3, High: 3, Low: 1, Close: 1},
+	}, cs)
+
+	intraday := FromOHLCTuples([][]float64{
+		{float64(day(1, 4)), 10, 12, 9, 11},
+		{float64(day(1, 8)), 11, 14, 10, 13},
+		{float64(day(1, 12)), 13, 13, 8, 9},
+		{float64(day(2, 4)), 9, 10, 9, 10},
+		{1}, // Skipped.
+	})
+	r.Len(intraday, 4)
+
+	r.Equal(Candles{
+		{TS: day(1, 4), Open: 10, High: 14, Low: 8, Close: 9},
+		{TS: day(2, 4), Open: 9, High: 10, Low: 9, Close: 10},
+	}, intraday.Daily())
+
+	r.Equal(Series{{day(1, 4), 9}, {day(2, 4), 10}}, intraday.Daily().Closes())
+
+	// Candles close after the previous price, up to and including the next.
+	r.Equal(Candles{
+		{TS: day(1, 0), Open: 2, High: 2, Low: 2, Close: 2},
+		{TS: day(2, 0), Open: 10, High: 14, Low: 8, Close: 9},
+		{TS: day(3, 0), Open: 9, High: 10, Low: 5, Close: 5},
+		{TS: day(4, 0), Open: 5, High: 6, Low: 5, Close: 6},
+	}, intraday.Resample(Series{
+		{day(1, 0), 2.0},
+		{day(2, 0), 9.0},
+		{day(3, 0), 5.0},
+		{day(4, 0), 6.0},
+	}))
+}
@@ -0,0 +1,230 @@
+package trade
+
+import (
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/anrid/traderbot/pkg/coingecko"
+	"github.com/anrid/traderbot/pkg/timeseries"
+	"github.com/pkg/errors"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// RunStrategy feeds the daily prices of the tracked market through a
+// strategy bar by bar, and turns its orders into trades on the traded
+// market at the price of the same day, or the order's price if set. Track
+// and trade may be the same market.
+func RunStrategy(s Strategy, track, trade *coingecko.Market) ([]*Trade, error) {
+	var trades []*Trade
+
+	for i, p := range track.Prices {
+		orders, err := s.Next(Bar{Index: i, TS: p.TS, Price: p.V})
+		if err != nil {
+			return nil, errors.Wrapf(err, "strategy %s failed at %s", s.Name(), p.Date())
+		}
+
+		for _, o := range orders {
+			t, err := NewTrade(o.Side, p.Date(), o.Size, trade)
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not create %s trade for %s", o.Side, trade.ID)
+			}
+			if o.Price > 0 {
+				t.Price = o.Price
+			}
+			trades = append(trades, t)
+		}
+	}
+
+	return trades, nil
+}
+
+// BacktestResult is the outcome of executing trades, see ExecuteTrades.
+type BacktestResult struct {
+	Title             string
+	Market            *coingecko.Market // The traded market
+	InitialInvestment float64
+	Fills             []*Fill
+	Equity            []EquityPoint // One point per price of the traded market
+
+	Buys         int
+	Sells        int
+	Skipped      int // Sells before the first buy
+	FirstBuyDate string
+	LastSellDate string
+
+	Cash       float64 // Fiat left after the last fill
+	Units      float64 // Units held after the last fill
+	FinalValue float64 // Cash plus an open position at the latest price
+	PL         float64 // Profit/loss as a percentage of the initial investment
+	Costs      Costs   // Total costs of all fills
+
+	Benchmarks []*Benchmark // Buying and holding the traded market first, see AddBenchmark
+}
+
+// Fill is an executed trade.
+type Fill struct {
+	Trade  *Trade
+	Amount float64 // Fiat spent or received, including costs
+	Units  float64 // Units bought or sold
+	Cash   float64 // Fiat after the fill
+	Held   float64 // Units held after the fill
+	Costs  Costs
+}
+
+type EquityPoint struct {
+	TS    int64   `json:"ts"`
+	Price float64 `json:"price"`
+	Cash  float64 `json:"cash"`
+	Units float64 `json:"units"`
+	Value float64 `json:"value"` // Cash + units x price
+}
+
+func (e EquityPoint) Date() string {
+	return timeseries.FromTSToDate(e.TS)
+}
+
+// ExecuteTrades simulates trades, in order, on a market starting with the
+// initial investment in fiat. Buys spend a percentage of the fiat and sells
+// sell a percentage of the units held. Sells before the first buy are
+// skipped. Trades are free, see ExecuteTradesWithCosts.
+func ExecuteTrades(title string, initialInvestment float64, m *coingecko.Market, ts []*Trade) *BacktestResult {
+	return ExecuteTradesWithCosts(title, initialInvestment, m, ts, nil)
+}
+
+// ExecuteTradesWithCosts is ExecuteTrades with trading costs, if the cost
+// model isn't nil. Costs are paid out of the amount spent on a buy, buying
+// fewer units, and out of the proceeds of a sell, and never exceed them.
+// Trades of nothing, e.g. buys without any fiat left, are free.
+func ExecuteTradesWithCosts(title string, initialInvestment float64, m *coingecko.Market, ts []*Trade, costs CostModel) *BacktestResult {
+	r := &BacktestResult{
+		Title:             title,
+		Market:            m,
+		InitialInvestment: initialInvestment,
+		Cash:              initialInvestment,
+	}
+
+	for _, t := range ts {
+		f := &Fill{Trade: t}
+
+		if t.Side == Buy {
+			pct := t.Size / 100.0
+			f.Amount = r.Cash * pct
+			if costs != nil && f.Amount > 0 {
+				f.Costs = costs.Costs(t, f.Amount).upTo(f.Amount)
+			}
+			f.Units = math.Max(f.Amount-f.Costs.Total(), 0) / t.Price
+
+			r.Cash -= f.Amount
+			r.Units += f.Units
+
+			r.Buys++
+			if r.FirstBuyDate == "" {
+				r.FirstBuyDate = t.Date
+			}
+		} else if t.Side == Sell {
+			if r.Buys == 0 {
+				// Skip all sell trades until we find first buy.
+				r.Skipped++
+				continue
+			}
+
+			pct := t.Size / 100.0
+			f.Units = r.Units * pct
+			f.Amount = f.Units * t.Price
+			if costs != nil && f.Amount > 0 {
+				f.Costs = costs.Costs(t, f.Amount).upTo(f.Amount)
+				f.Amount = math.Max(f.Amount-f.Costs.Total(), 0)
+			}
+
+			r.Cash += f.Amount
+			r.Units -= f.Units
+
+			r.Sells++
+			r.LastSellDate = t.Date
+		} else {
+			continue
+		}
+
+		f.Cash, f.Held = r.Cash, r.Units
+		r.Fills = append(r.Fills, f)
+		r.Costs = r.Costs.Add(f.Costs)
+	}
+
+	// Equity curve, applying fills at the end of their day.
+	cash, units := initialInvestment, 0.0
+	var next int
+	for _, p := range m.Prices {
+		for ; next < len(r.Fills) && r.Fills[next].Trade.Date <= p.Date(); next++ {
+			cash, units = r.Fills[next].Cash, r.Fills[next].Held
+		}
+		r.Equity = append(r.Equity, EquityPoint{TS: p.TS, Price: p.V, Cash: cash, Units: units, Value: cash + units*p.V})
+	}
+
+	r.FinalValue = r.Cash
+	if r.Units > 0 && len(m.Prices) > 0 {
+		// We still hold units, e.g. after a partial sell; calculate the
+		// value of the position today.
+		latestPrice := m.Prices[len(m.Prices)-1].V
+		r.FinalValue += r.Units * latestPrice
+	}
+
+	r.PL = (r.FinalValue/initialInvestment - 1) * 100.0
+
+	r.AddBenchmark(m)
+
+	return r
+}
+
+// Print prints the fills and a summary to stdout.
+func (r *BacktestResult) Print() {
+	r.Fprint(os.Stdout)
+}
+
+// Fprint prints the fills and a summary, or nothing if there were no
+// trades. Trades that were all skipped still print an empty summary.
+func (r *BacktestResult) Fprint(w io.Writer) {
+	if len(r.Fills) == 0 && r.Skipped == 0 {
+		return
+	}
+
+	pr := message.NewPrinter(language.English)
+	m := r.Market
+
+	pr.Fprintf(w, "\n\nTrading '%s' (%s) : %s\n", m.Name, strings.ToUpper(m.Symbol), r.Title)
+	pr.Fprintf(w, "=============================================================\n\n")
+
+	for n, f := range r.Fills {
+		t := f.Trade
+		if t.Side == Buy {
+			pr.Fprintf(w, "%3d. [%s] %-4s %-10s @ %14.04f  --  amount: %14.04f , units: %14.04f\n",
+				n+1, t.Date, "buy", t.Market.ID, t.Price, f.Amount, f.Units,
+			)
+		} else {
+			pr.Fprintf(w, "%3d. [%s] %-4s %-10s @ %14.04f  --  amount: %14.04f , units: %14.04f  [portfolio: %14.04f]\n",
+				n+1, t.Date, "sell", t.Market.ID, t.Price, f.Amount, f.Units, f.Cash,
+			)
+		}
+	}
+
+	rangeStart, _ := time.Parse("2006-01-02", r.FirstBuyDate)
+	rangeEnd, _ := time.Parse("2006-01-02", r.LastSellDate)
+	daysDiff := rangeEnd.Sub(rangeStart).Hours() / 24
+
+	pr.Fprintf(w, "\n\n")
+	pr.Fprintf(w, "- Number of txns     : %d\n", len(r.Fills))
+	pr.Fprintf(w, "- First buy          : %s\n", r.FirstBuyDate)
+	pr.Fprintf(w, "- Last sell          : %s  (%.f days after first buy)\n", r.LastSellDate, daysDiff)
+
+	pr.Fprintf(w, "- Initial investment : %.02f\n", r.InitialInvestment)
+	pr.Fprintf(w, "- Portfolio value    : %.02f\n", r.FinalValue)
+	if r.Costs.Total() > 0 {
+		pr.Fprintf(w, "- Trading costs      : %.02f  (fees: %.02f , spread: %.02f , slippage: %.02f)\n",
+			r.Costs.Total(), r.Costs.Fees, r.Costs.Spread, r.Costs.Slippage)
+	}
+
+	pr.Fprintf(w, "- P/L                : %.02f %%\n\n\n", r.PL)
+}
@@ -0,0 +1,287 @@
+package trade
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/anrid/traderbot/pkg/coingecko"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunStrategyEMACrossOver(t *testing.T) {
+	r := require.New(t)
+
+	var vs []float64
+	for i := 0; i < 120; i++ {
+		vs = append(vs, 100+20*math.Sin(float64(i)/6)+float64(i)/4)
+	}
+	prices, _ := dailyPrices(vs...)
+	market := &coingecko.Market{ID: "test", Prices: prices}
+
+	short := NewEMAIndicator(9, prices)
+	long := NewEMAIndicator(21, prices)
+
+	s := NewEMACrossOverStrategy(short, long)
+	r.Equal("9-Day EMA/21-Day EMA CrossOver Strategy", s.Name())
+
+	trades, err := RunStrategy(s, market, market)
+	r.NoError(err)
+	r.NotEmpty(trades)
+
+	// Identical to the trades calculated before strategies ran bar by bar.
+	var want []*Trade
+	var lastShort, lastLong float64
+	for _, p := range prices {
+		short, long := short.ForTimestamp(p.TS), long.ForTimestamp(p.TS)
+		if short == 0.0 || long == 0.0 {
+			continue
+		}
+		if lastShort > 0.0 && lastLong > 0.0 {
+			if lastShort > lastLong && short < long {
+				tr, err := NewSellAtDate(p.Date(), 100.0, market)
+				r.NoError(err)
+				want = append(want, tr)
+			} else if lastShort < lastLong && short > long {
+				tr, err := NewBuyAtDate(p.Date(), 100.0, market)
+				r.NoError(err)
+				want = append(want, tr)
+			}
+		}
+		lastShort, lastLong = short, long
+	}
+	r.Equal(want, trades)
+
+	// Strategies reset on the first bar.
+	again, err := RunStrategy(s, market, market)
+	r.NoError(err)
+	r.Equal(want, again)
+}
+
+func TestEMACrossOverStrategyNegativeValues(t *testing.T) {
+	r := require.New(t)
+
+	_, days := dailyPrices(0, 0, 0, 0, 0)
+	indicator := func(name string, vs map[int]float64) *Indicator {
+		i := &Indicator{Name: name, ByTimestamp: make(map[int64]float64)}
+		for day, v := range vs {
+			i.ByTimestamp[days[day]] = v
+		}
+		return i
+	}
+
+	// E.g. the MACD histogram crossing over its zero line.
+	short := indicator("hist", map[int]float64{0: -1, 1: 1, 2: -1, 3: 0, 4: 1})
+	long := indicator("zero", map[int]float64{0: 0, 1: 0, 2: -0.5, 3: 0.5, 4: 0.5})
+	s := NewEMACrossOverStrategy(short, long)
+
+	var sides []string
+	for run := 0; run < 2; run++ {
+		sides = nil
+		for i, ts := range days {
+			orders, err := s.Next(Bar{Index: i, TS: ts})
+			r.NoError(err)
+			for _, o := range orders {
+				sides = append(sides, fmt.Sprintf("%d:%s", i, o.Side))
+			}
+		}
+	}
+	r.Equal([]string{"1:buy", "2:sell", "4:buy"}, sides)
+}
+
+type scriptedStrategy struct {
+	orders map[int][]Order
+	bars   []Bar
+	err    error
+}
+
+func (s *scriptedStrategy) Name() string { return "scripted" }
+
+func (s *scriptedStrategy) Next(bar Bar) ([]Order, error) {
+	s.bars = append(s.bars, bar)
+	if s.err != nil && bar.Index == 1 {
+		return nil, s.err
+	}
+	return s.orders[bar.Index], nil
+}
+
+func TestRunStrategy(t *testing.T) {
+	r := require.New(t)
+
+	trackPrices, days := dailyPrices(1, 2, 3)
+	tradePrices, _ := dailyPrices(10, 20, 30)
+	track := &coingecko.Market{ID: "track", Prices: trackPrices}
+	trade := &coingecko.Market{ID: "trade", Prices: tradePrices}
+
+	s := &scriptedStrategy{orders: map[int][]Order{
+		0: {{Side: Buy, Size: 50}},
+		2: {{Side: Sell, Size: 100}, {Side: Buy, Size: 25}},
+	}}
+
+	trades, err := RunStrategy(s, track, trade)
+	r.NoError(err)
+
+	r.Len(s.bars, 3)
+	r.Equal(Bar{Index: 1, TS: days[1], Price: 2}, s.bars[1])
+	r.Equal("2021-01-02", s.bars[1].Date())
+
+	r.Len(trades, 3)
+	r.Equal(Buy, trades[0].Side)
+	r.Equal(50.0, trades[0].Size)
+	r.Equal(10.0, trades[0].Price)
+	r.Equal(Sell, trades[1].Side)
+	r.Equal(30.0, trades[1].Price)
+	r.Equal(Buy, trades[2].Side)
+	r.Equal("2021-01-03", trades[2].Date)
+	r.Equal(trade, trades[2].Market)
+
+	// Missing prices on the traded market.
+	_, err = RunStrategy(&scriptedStrategy{orders: s.orders}, track, &coingecko.Market{ID: "empty"})
+	r.EqualError(err, "could not create buy trade for empty: could not find a price for `empty` at date 2021-01-01")
+
+	_, err = RunStrategy(&scriptedStrategy{err: errors.New("boom")}, track, trade)
+	r.EqualError(err, "strategy scripted failed at 2021-01-02: boom")
+}
+
+func testTrades(r *require.Assertions) (*coingecko.Market, []*Trade) {
+	prices, _ := dailyPrices(10, 12, 8, 16, 20, 1250.5, 25)
+	m := &coingecko.Market{ID: "test", Name: "Test Coin", Symbol: "tst", Prices: prices}
+
+	var ts []*Trade
+	add := func(side Side, i int, size float64) {
+		t, err := NewTrade(side, prices[i].Date(), size, m)
+		r.NoError(err)
+		ts = append(ts, t)
+	}
+	add(Sell, 0, 100) // Skipped, before the first buy.
+	add(Buy, 1, 50)
+	add(Buy, 2, 100)
+	add(Sell, 3, 50)
+	add(Sell, 4, 100)
+	add(Buy, 5, 100)
+
+	return m, ts
+}
+
+func TestExecuteTrades(t *testing.T) {
+	r := require.New(t)
+
+	m, ts := testTrades(r)
+
+	res := ExecuteTrades("Golden", 10_000, m, ts)
+	r.Len(res.Fills, 5)
+	r.Equal(2, res.Sells)
+	r.Equal(3, res.Buys)
+	r.Equal("2021-01-02", res.FirstBuyDate)
+	r.Equal("2021-01-05", res.LastSellDate)
+
+	// 5,000 buys 416.67 units at 12, 5,000 buys 625 at 8.
+	r.Equal(ts[2], res.Fills[1].Trade)
+	r.InDelta(625.0, res.Fills[1].Units, 1e-9)
+	r.Equal(0.0, res.Fills[1].Cash)
+	r.InDelta(1041.666667, res.Fills[1].Held, 1e-6)
+
+	// Everything is sold for 18,750 and bought back at 1,250.5.
+	r.Equal(0.0, res.Cash)
+	r.InDelta(18750/1250.5, res.Units, 1e-9)
+	r.InDelta(18750/1250.5*25, res.FinalValue, 1e-9)
+	r.InDelta((18750/1250.5*25/10_000-1)*100, res.PL, 1e-9)
+
+	r.Len(res.Equity, 7)
+	var values []float64
+	for _, e := range res.Equity {
+		values = append(values, math.Round(e.Value*100)/100)
+	}
+	r.Equal([]float64{10_000, 10_000, 8_333.33, 16_666.67, 18_750, 18_750, 374.85}, values)
+	r.Equal(5_000.0, res.Equity[1].Cash)
+	r.Equal("2021-01-07", res.Equity[6].Date())
+
+	// A partial sell leaves units that count towards the final value and
+	// the excess return alike.
+	prices, _ := dailyPrices(10, 20, 40)
+	partial := &coingecko.Market{ID: "test", Prices: prices}
+	var pts []*Trade
+	for i, side := range []Side{Buy, Sell} {
+		tr, err := NewTrade(side, prices[i].Date(), []float64{100, 50}[i], partial)
+		r.NoError(err)
+		pts = append(pts, tr)
+	}
+	res = ExecuteTrades("Partial", 10_000, partial, pts)
+	r.Equal(1, res.Buys)
+	r.Equal(1, res.Sells)
+	r.InDelta(500, res.Units, 1e-9)
+	r.InDelta(30_000, res.FinalValue, 1e-9)
+	r.InDelta(30_000, res.Equity[2].Value, 1e-9)
+	r.InDelta(200, res.PL, 1e-9)
+	r.InDelta(2.0-3.0, res.Benchmarks[0].ExcessReturn, 1e-9)
+
+	// No trades.
+	res = ExecuteTrades("None", 10_000, m, nil)
+	r.Empty(res.Fills)
+	r.Equal(10_000.0, res.FinalValue)
+	r.Equal(0.0, res.PL)
+	r.Len(res.Equity, 7)
+
+	var b strings.Builder
+	res.Fprint(&b)
+	r.Empty(b.String())
+
+	// Only skipped sells.
+	res = ExecuteTrades("Skipped", 10_000, m, ts[:1])
+	r.Empty(res.Fills)
+	r.Equal(1, res.Skipped)
+
+	res.Fprint(&b)
+	r.Equal(skippedBacktestOutput, b.String())
+}
+
+const skippedBacktestOutput = `
+
+Trading 'Test Coin' (TST) : Skipped
+=============================================================
+
+
+
+- Number of txns     : 0
+- First buy          : 
+- Last sell          :   (0 days after first buy)
+- Initial investment : 10,000.00
+- Portfolio value    : 10,000.00
+- P/L                : 0.00 %
+
+
+`
+
+func TestBacktestResultFprint(t *testing.T) {
+	r := require.New(t)
+
+	m, ts := testTrades(r)
+
+	var b strings.Builder
+	ExecuteTrades("Golden", 10_000, m, ts).Fprint(&b)
+	r.Equal(goldenBacktestOutput, b.String())
+}
+
+const goldenBacktestOutput = `
+
+Trading 'Test Coin' (TST) : Golden
+=============================================================
+
+  1. [2021-01-02] buy  test       @        12.0000  --  amount:     5,000.0000 , units:       416.6667
+  2. [2021-01-03] buy  test       @         8.0000  --  amount:     5,000.0000 , units:       625.0000
+  3. [2021-01-04] sell test       @        16.0000  --  amount:     8,333.3333 , units:       520.8333  [portfolio:     8,333.3333]
+  4. [2021-01-05] sell test       @        20.0000  --  amount:    10,416.6667 , units:       520.8333  [portfolio:    18,750.0000]
+  5. [2021-01-06] buy  test       @     1,250.5000  --  amount:    18,750.0000 , units:        14.9940
+
+
+- Number of txns     : 5
+- First buy          : 2021-01-02
+- Last sell          : 2021-01-05  (3 days after first buy)
+- Initial investment : 10,000.00
+- Portfolio value    : 374.85
+- P/L                : -96.25 %
+
+
+`
@@ -0,0 +1,133 @@
+package trade
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/anrid/traderbot/pkg/coingecko"
+	"github.com/anrid/traderbot/pkg/timeseries"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// Benchmark compares a backtest with buying and holding a market over the
+// same period. Returns are fractions, e.g. 0.25 for 25%.
+type Benchmark struct {
+	Name   string
+	Market *coingecko.Market
+	Equity []EquityPoint
+
+	Return       float64 // Total return of buying and holding
+	ExcessReturn float64 // Total return of the backtest minus Return
+	Alpha        float64 // Annualized daily return of the backtest not explained by Beta
+	Beta         float64 // Sensitivity of the backtest's daily returns to the benchmark's
+}
+
+// AddBenchmark compares the result with buying and holding a market from
+// the first day of the backtest, e.g. bitcoin. ExecuteTrades adds the
+// traded market as the first benchmark.
+func (r *BacktestResult) AddBenchmark(m *coingecko.Market) *Benchmark {
+	b := &Benchmark{
+		Name:   fmt.Sprintf("Buy & Hold %s", strings.ToUpper(m.Symbol)),
+		Market: m,
+	}
+	if m.Symbol == "" {
+		b.Name = fmt.Sprintf("Buy & Hold %s", m.ID)
+	}
+
+	var from string
+	if len(r.Equity) > 0 {
+		from = r.Equity[0].Date()
+	}
+	b.Equity = buyAndHold(r.InitialInvestment, m, from)
+
+	if len(b.Equity) > 0 && len(r.Equity) > 0 {
+		b.Return = b.Equity[len(b.Equity)-1].Value/r.InitialInvestment - 1
+		b.ExcessReturn = r.FinalValue/r.InitialInvestment - 1 - b.Return
+		b.Alpha, b.Beta = alphaBeta(r.Equity, b.Equity)
+	}
+
+	r.Benchmarks = append(r.Benchmarks, b)
+	return b
+}
+
+// buyAndHold spends the initial investment at the first price on or after
+// the from date (YYYY-MM-DD) and holds.
+func buyAndHold(initialInvestment float64, m *coingecko.Market, from string) (equity []EquityPoint) {
+	var units float64
+	for _, p := range m.Prices {
+		if p.Date() < from || (units == 0 && p.V <= 0) {
+			continue
+		}
+		if units == 0 {
+			units = initialInvestment / p.V
+		}
+		equity = append(equity, EquityPoint{TS: p.TS, Price: p.V, Units: units, Value: units * p.V})
+	}
+	return
+}
+
+// alphaBeta regresses the daily returns of a backtest on those of a
+// benchmark, on the days found in both.
+func alphaBeta(equity, benchmark []EquityPoint) (alpha, beta float64) {
+	toSeries := func(es []EquityPoint) (s timeseries.Series) {
+		for _, e := range es {
+			s = append(s, timeseries.ValueAt{TS: e.TS, V: e.Value})
+		}
+		return
+	}
+	a, b := timeseries.Align(toSeries(equity), toSeries(benchmark))
+
+	var ra, rb []float64
+	for i := 1; i < len(a); i++ {
+		if a[i-1].V > 0 && b[i-1].V > 0 {
+			ra = append(ra, a[i].V/a[i-1].V-1)
+			rb = append(rb, b[i].V/b[i-1].V-1)
+		}
+	}
+
+	n := float64(len(ra))
+	if n < 2 {
+		return
+	}
+
+	var meanA, meanB float64
+	for i := range ra {
+		meanA += ra[i] / n
+		meanB += rb[i] / n
+	}
+
+	var cov, variance float64
+	for i := range ra {
+		cov += (ra[i] - meanA) * (rb[i] - meanB)
+		variance += (rb[i] - meanB) * (rb[i] - meanB)
+	}
+	if variance == 0 {
+		return
+	}
+
+	beta = cov / variance
+	alpha = (meanA - beta*meanB) * PeriodsPerYear
+	return
+}
+
+// PrintBenchmarks prints the benchmarks to stdout.
+func (r *BacktestResult) PrintBenchmarks() {
+	r.FprintBenchmarks(os.Stdout)
+}
+
+// FprintBenchmarks prints the benchmarks in the style of the BacktestResult
+// summary.
+func (r *BacktestResult) FprintBenchmarks(w io.Writer) {
+	pr := message.NewPrinter(language.English)
+
+	for _, b := range r.Benchmarks {
+		pr.Fprintf(w, "- %-18s : %.02f %%  (excess return: %.02f %% , alpha: %.02f %% , beta: %.02f)\n",
+			b.Name, b.Return*100, b.ExcessReturn*100, b.Alpha*100, b.Beta)
+	}
+	if len(r.Benchmarks) > 0 {
+		pr.Fprintf(w, "\n\n")
+	}
+}
@@ -0,0 +1,62 @@
+package trade
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anrid/traderbot/pkg/coingecko"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchmarks(t *testing.T) {
+	r := require.New(t)
+
+	prices, _ := dailyPrices(0, 10, 20, 10, 40)
+	m := &coingecko.Market{ID: "test", Name: "Test Coin", Symbol: "tst", Prices: prices[1:]}
+
+	var ts []*Trade
+	for i, side := range []Side{Buy, Sell, Buy} {
+		tr, err := NewTrade(side, prices[i+1].Date(), 100, m)
+		r.NoError(err)
+		ts = append(ts, tr)
+	}
+
+	// Strategy equity = [10,000, 20,000, 20,000, 80,000]
+	// Buy & hold      = [10,000, 20,000, 10,000, 40,000]
+	res := ExecuteTrades("Test", 10_000, m, ts)
+	r.Len(res.Benchmarks, 1)
+
+	b := res.Benchmarks[0]
+	r.Equal("Buy & Hold TST", b.Name)
+	r.Len(b.Equity, 4)
+	r.InDelta(40_000, b.Equity[3].Value, 1e-9)
+	r.InDelta(3.0, b.Return, 1e-9)
+	r.InDelta(7.0-3.0, b.ExcessReturn, 1e-9)
+
+	// Daily returns are [1, 0, 3] and [1, -0.5, 3], with means 4/3 and 3.5/3.
+	// Covariance = 5.3333 / 3, benchmark variance = 6.1667 / 3.
+	r.InDelta(32.0/37, b.Beta, 1e-9)
+	r.InDelta((4.0/3-32.0/37*3.5/3)*365, b.Alpha, 1e-9)
+
+	// Bitcoin has an earlier price, before the backtest starts.
+	btcPrices, _ := dailyPrices(50, 100, 100, 100, 200)
+	btc := res.AddBenchmark(&coingecko.Market{ID: "bitcoin", Symbol: "btc", Prices: btcPrices})
+	r.Len(res.Benchmarks, 2)
+	r.Len(btc.Equity, 4)
+	r.Equal(100.0, btc.Equity[0].Units)
+	r.InDelta(1.0, btc.Return, 1e-9)
+	r.InDelta(6.0, btc.ExcessReturn, 1e-9)
+
+	var out strings.Builder
+	res.FprintBenchmarks(&out)
+	r.Contains(out.String(), "- Buy & Hold TST     : 300.00 %  (excess return: 400.00 % , alpha: ")
+	r.Contains(out.String(), "- Buy & Hold BTC     : 100.00 %  (excess return: 600.00 % , alpha: ")
+
+	dir := t.TempDir()
+	r.NoError(RenderBacktestChart(dir, res))
+	html, err := os.ReadFile(filepath.Join(dir, "backtest-test-2021-01-02-2021-01-05.html"))
+	r.NoError(err)
+	r.Contains(string(html), "Buy \\u0026 Hold BTC")
+}
@@ -167,3 +167,118 @@ func RenderYieldFarmingPerformanceChart(path string, farm *LPFarm) error {
 
 	return nil
 }
+
+// RenderBacktestChart writes a chart of the equity curve of a backtest and
+// its benchmarks to an HTML file in path.
+func RenderBacktestChart(path string, r *BacktestResult) error {
+	if len(r.Equity) == 0 {
+		return errors.Errorf("no equity curve to chart for %s", r.Title)
+	}
+
+	pr := message.NewPrinter(language.English)
+
+	first := r.Equity[0].Date()
+	last := r.Equity[len(r.Equity)-1].Date()
+
+	title := pr.Sprintf("%s  --  [%s - %s]", r.Title, first, last)
+	subtitle := pr.Sprintf("Trading %s (%s), Initial Investment: %.f %s",
+		r.Market.Name,
+		strings.ToUpper(r.Market.Symbol),
+		r.InitialInvestment,
+		strings.ToUpper(string(r.Market.Currency)),
+	)
+
+	filename := strings.ToLower(pr.Sprintf("backtest-%s-%s-%s.html", r.Market.ID, first, last))
+
+	fontFamily := "Source Code Pro"
+
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithInitializationOpts(opts.Initialization{
+			Theme:  types.ThemeVintage,
+			Width:  "1000px",
+			Height: "700px",
+		}),
+		charts.WithTitleOpts(opts.Title{
+			Title:    title,
+			Subtitle: subtitle,
+			TitleStyle: &opts.TextStyle{
+				FontFamily: fontFamily,
+			},
+			SubtitleStyle: &opts.TextStyle{
+				FontFamily: fontFamily,
+			},
+		}),
+		charts.WithLegendOpts(opts.Legend{
+			Show:   true,
+			Bottom: "1px",
+			TextStyle: &opts.TextStyle{
+				FontSize:   12,
+				FontFamily: fontFamily,
+			},
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Type: "value",
+			SplitLine: &opts.SplitLine{
+				Show: true,
+				LineStyle: &opts.LineStyle{
+					Type: "dotted",
+				},
+			},
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Name: "Date",
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show: true,
+		}),
+	)
+
+	// One point per day of the backtest; benchmarks may lack some days.
+	var dates []string
+	seen := make(map[string]bool)
+	for _, e := range r.Equity {
+		if !seen[e.Date()] {
+			seen[e.Date()] = true
+			dates = append(dates, e.Date())
+		}
+	}
+
+	toLineData := func(equity []EquityPoint) (items []opts.LineData) {
+		byDate := make(map[string]float64)
+		for _, e := range equity {
+			byDate[e.Date()] = e.Value
+		}
+		for _, d := range dates {
+			if v, found := byDate[d]; found {
+				items = append(items, opts.LineData{Value: int64(v)})
+			} else {
+				items = append(items, opts.LineData{Value: nil})
+			}
+		}
+		return
+	}
+
+	final := r.Equity[len(r.Equity)-1].Value
+	line.SetXAxis(dates).
+		AddSeries(pr.Sprintf("Strategy: %.f (%.f%%)", final, (final/r.InitialInvestment-1)*100), toLineData(r.Equity))
+	for _, b := range r.Benchmarks {
+		if len(b.Equity) == 0 {
+			continue
+		}
+		line.AddSeries(pr.Sprintf("%s: %.f (%.f%%)", b.Name, b.Equity[len(b.Equity)-1].Value, b.Return*100), toLineData(b.Equity))
+	}
+
+	page := components.NewPage()
+	page.AddCharts(line).SetLayout(components.PageFlexLayout)
+
+	file := filepath.Join(path, filename)
+	fmt.Printf("writing chart %s\n", file)
+	f, err := os.Create(file)
+	if err != nil {
+		return errors.Wrapf(err, "could not write chart to file %s", file)
+	}
+	defer f.Close()
+
+	return page.Render(f)
+}
@@ -0,0 +1,66 @@
+package trade
+
+// CostModel calculates the trading costs of a fill, see
+// ExecuteTradesWithCosts. Amount is the fiat spent on a buy, or the value
+// of the units sold at the trade price.
+type CostModel interface {
+	Costs(t *Trade, amount float64) Costs
+}
+
+// Costs are the fiat lost to friction when trading.
+type Costs struct {
+	Fees     float64 `json:"fees"`
+	Spread   float64 `json:"spread"`
+	Slippage float64 `json:"slippage"`
+}
+
+func (c Costs) Total() float64 {
+	return c.Fees + c.Spread + c.Slippage
+}
+
+func (c Costs) Add(o Costs) Costs {
+	return Costs{
+		Fees:     c.Fees + o.Fees,
+		Spread:   c.Spread + o.Spread,
+		Slippage: c.Slippage + o.Slippage,
+	}
+}
+
+// upTo scales the costs down to at most a total of max, e.g. the amount
+// they're paid out of.
+func (c Costs) upTo(max float64) Costs {
+	total := c.Total()
+	if total <= max || total == 0 {
+		return c
+	}
+	k := max / total
+	return Costs{Fees: c.Fees * k, Spread: c.Spread * k, Slippage: c.Slippage * k}
+}
+
+// TradingCosts is a CostModel of exchange fees, the bid/ask spread and
+// slippage. Percentages are expressed as float64s, e.g. 0.1 for 0.1%.
+type TradingCosts struct {
+	FeePct    float64 // Fee as a percentage of the amount traded
+	FixedFee  float64 // Fee in fiat per trade
+	SpreadPct float64 // Bid/ask spread, half of which is lost on every trade
+	// SlippagePct is the price impact as a percentage per 1% of the day's
+	// total volume traded, e.g. trading 2% of the volume with a SlippagePct
+	// of 0.5 costs 1%. There's no slippage on days without volume data.
+	SlippagePct float64
+}
+
+func (c *TradingCosts) Costs(t *Trade, amount float64) Costs {
+	costs := Costs{
+		Fees:   amount*c.FeePct/100 + c.FixedFee,
+		Spread: amount * c.SpreadPct / 2 / 100,
+	}
+
+	if c.SlippagePct > 0 && t.Market != nil {
+		if v, found := t.Market.TotalVolumes.AtDate(t.Date); found && v.V > 0 {
+			pctOfVolume := amount / v.V * 100
+			costs.Slippage = amount * pctOfVolume * c.SlippagePct / 100
+		}
+	}
+
+	return costs
+}
@@ -0,0 +1,73 @@
+package trade
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/anrid/traderbot/pkg/coingecko"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecuteTradesWithCosts(t *testing.T) {
+	r := require.New(t)
+
+	prices, _ := dailyPrices(10, 20)
+	volumes, _ := dailyPrices(1_000_000, 0)
+	m := &coingecko.Market{ID: "test", Prices: prices, TotalVolumes: volumes}
+
+	buy, err := NewBuyAtDate(prices[0].Date(), 100, m)
+	r.NoError(err)
+	sell, err := NewSellAtDate(prices[1].Date(), 100, m)
+	r.NoError(err)
+
+	costs := &TradingCosts{FeePct: 0.1, FixedFee: 1, SpreadPct: 0.2, SlippagePct: 0.5}
+	res := ExecuteTradesWithCosts("Test", 10_000, m, []*Trade{buy, sell}, costs)
+	r.Len(res.Fills, 2)
+
+	// Buying for 10,000 costs 10 + 1 in fees, half of a 0.2% spread and
+	// 0.5% slippage per 1% of the day's volume.
+	f := res.Fills[0]
+	r.InDelta(11.0, f.Costs.Fees, 1e-9)
+	r.InDelta(10.0, f.Costs.Spread, 1e-9)
+	r.InDelta(50.0, f.Costs.Slippage, 1e-9)
+	r.Equal(10_000.0, f.Amount)
+	r.InDelta(9_929.0/10, f.Units, 1e-9)
+
+	// Selling 992.9 units at 20 = 19,858 on a day without volume data.
+	f = res.Fills[1]
+	r.InDelta(19.858+1, f.Costs.Fees, 1e-9)
+	r.InDelta(19.858, f.Costs.Spread, 1e-9)
+	r.Equal(0.0, f.Costs.Slippage)
+	r.InDelta(19_858-20.858-19.858, f.Amount, 1e-9)
+	r.InDelta(19_858-20.858-19.858, res.FinalValue, 1e-9)
+
+	r.InDelta(31.858, res.Costs.Fees, 1e-9)
+	r.InDelta(29.858, res.Costs.Spread, 1e-9)
+	r.InDelta(50.0, res.Costs.Slippage, 1e-9)
+	r.InDelta(111.716, res.Costs.Total(), 1e-9)
+
+	var b strings.Builder
+	res.Fprint(&b)
+	r.Contains(b.String(), "- Trading costs      : 111.72  (fees: 31.86 , spread: 29.86 , slippage: 50.00)\n")
+
+	// Costs never exceed the amount traded, and trading nothing is free.
+	small := &TradingCosts{FixedFee: 15, SpreadPct: 10}
+	res = ExecuteTradesWithCosts("Test", 10, m, []*Trade{buy, buy, sell}, small)
+	r.Len(res.Fills, 3)
+
+	f = res.Fills[0]
+	r.InDelta(10.0, f.Costs.Total(), 1e-9)
+	r.InDelta(10.0*15/15.5, f.Costs.Fees, 1e-9)
+	r.InDelta(10.0*0.5/15.5, f.Costs.Spread, 1e-9)
+	r.Equal(0.0, f.Units)
+
+	r.Equal(Costs{}, res.Fills[1].Costs)
+	r.Equal(Costs{}, res.Fills[2].Costs)
+	r.InDelta(10.0, res.Costs.Total(), 1e-9)
+	r.Equal(0.0, res.FinalValue)
+
+	// Free without a cost model.
+	free := ExecuteTrades("Test", 10_000, m, []*Trade{buy, sell})
+	r.Equal(Costs{}, free.Costs)
+	r.InDelta(20_000.0, free.FinalValue, 1e-9)
+}
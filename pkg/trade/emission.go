@@ -0,0 +1,209 @@
+package trade
+
+import (
+	"math"
+	"time"
+
+	"github.com/anrid/traderbot/pkg/messari"
+	"github.com/anrid/traderbot/pkg/timeseries"
+	"github.com/pkg/errors"
+)
+
+const (
+	daysPerYear = 365.25
+)
+
+// EmissionModel projects the circulating supply of a token from Messari
+// supply metrics:
+//
+//   - Until Y+10 the supply grows at the current annual inflation rate,
+//     decaying (or accelerating) exponentially so that the supply reaches
+//     the Y+10 estimate exactly after 10 years.
+//   - From Y+10 until 2050 the supply grows at a constant rate towards the
+//     Y2050 estimate.
+//   - After the last known estimate the supply stays flat.
+//
+// Missing estimates are skipped. With only an inflation rate the supply
+// compounds at that rate forever; with no data at all it stays flat.
+type EmissionModel struct {
+	Symbol             string
+	Start              time.Time
+	Circulating        float64 // Circulating supply at Start
+	AnnualInflationPct float64
+	YPlus10            float64 // Estimated supply 10 years after Start
+	Y2050              float64 // Estimated supply in 2050
+
+	anchors []supplyAnchor
+	decay   float64 // Decay rate of the inflation rate until the first anchor
+	fitted  bool    // Whether the inflation rate was fitted to the first anchor
+}
+
+type supplyAnchor struct {
+	years  float64 // Years after Start
+	supply float64
+}
+
+func NewEmissionModel(a *messari.Asset, start time.Time) (*EmissionModel, error) {
+	s := a.Metrics.Supply
+
+	m := &EmissionModel{
+		Symbol:             a.Symbol,
+		Start:              start,
+		Circulating:        s.Circulating,
+		AnnualInflationPct: s.AnnualInflationPct,
+		YPlus10:            s.YPlus10,
+		Y2050:              s.Y2050,
+	}
+
+	err := m.Init()
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Init validates the model and fits it to the given estimates. It must be
+// called after changing any of the exported fields.
+func (m *EmissionModel) Init() error {
+	if m.Circulating <= 0 {
+		return errors.Errorf("no circulating supply for %s", m.Symbol)
+	}
+	if m.AnnualInflationPct <= -100 {
+		return errors.Errorf("invalid annual inflation %.02f%% for %s", m.AnnualInflationPct, m.Symbol)
+	}
+
+	m.anchors = nil
+	m.fitted = false
+	m.decay = 0
+
+	if m.YPlus10 > 0 {
+		m.anchors = append(m.anchors, supplyAnchor{10, m.YPlus10})
+	}
+	if m.Y2050 > 0 {
+		y2050 := time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC)
+		years := y2050.Sub(m.Start).Hours() / 24 / daysPerYear
+		if len(m.anchors) == 0 || years > m.anchors[len(m.anchors)-1].years {
+			m.anchors = append(m.anchors, supplyAnchor{years, m.Y2050})
+		}
+	}
+
+	if len(m.anchors) > 0 && m.AnnualInflationPct != 0 {
+		first := m.anchors[0]
+		r0 := m.rate()
+		g := math.Log(first.supply / m.Circulating)
+
+		// Only fit if the estimate grows the supply in the same direction
+		// as the current inflation rate does, and by an amount we can fit.
+		if g/r0 > 0 {
+			m.decay, m.fitted = fitDecay(g/r0, first.years)
+		}
+	}
+
+	return nil
+}
+
+// rate returns the current inflation as a continuously compounded rate.
+func (m *EmissionModel) rate() float64 {
+	return math.Log(1 + m.AnnualInflationPct/100)
+}
+
+// decayedYears returns ∫₀ᵗ e^(-k·s) ds, i.e. the number of years of
+// inflation at the initial rate that a rate decaying at k adds up to.
+func decayedYears(k, t float64) float64 {
+	if math.Abs(k) < 1e-12 {
+		return t
+	}
+	return (1 - math.Exp(-k*t)) / k
+}
+
+// fitDecay finds k such that decayedYears(k, t) = target using bisection.
+// decayedYears is strictly decreasing in k. It returns false if target is
+// out of the range of k it searches, where the fit would miss the target.
+func fitDecay(target, t float64) (float64, bool) {
+	lo, hi := -5.0, 50.0
+	if target > decayedYears(lo, t) || target < decayedYears(hi, t) {
+		return 0, false
+	}
+	for i := 0; i < 200; i++ {
+		mid := (lo + hi) / 2
+		if decayedYears(mid, t) > target {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2, true
+}
+
+// SupplyAt returns the projected circulating supply the given number of
+// years after Start.
+func (m *EmissionModel) SupplyAt(years float64) float64 {
+	if years <= 0 {
+		return m.Circulating
+	}
+
+	if len(m.anchors) == 0 {
+		return m.Circulating * math.Exp(m.rate()*years)
+	}
+
+	first := m.anchors[0]
+	if years <= first.years {
+		if m.fitted {
+			return m.Circulating * math.Exp(m.rate()*decayedYears(m.decay, years))
+		}
+		return m.Circulating * math.Pow(first.supply/m.Circulating, years/first.years)
+	}
+
+	for i := 1; i < len(m.anchors); i++ {
+		from, to := m.anchors[i-1], m.anchors[i]
+		if years <= to.years {
+			return from.supply * math.Pow(to.supply/from.supply, (years-from.years)/(to.years-from.years))
+		}
+	}
+
+	return m.anchors[len(m.anchors)-1].supply
+}
+
+// Supply returns the projected circulating supply at the given time.
+func (m *EmissionModel) Supply(t time.Time) float64 {
+	return m.SupplyAt(t.Sub(m.Start).Hours() / 24 / daysPerYear)
+}
+
+// SupplySeries returns the projected daily circulating supply for the
+// given number of days after Start.
+func (m *EmissionModel) SupplySeries(days int) (ts timeseries.Series) {
+	for day := 0; day <= days; day++ {
+		ts = append(ts, timeseries.ValueAt{
+			TS: m.Start.Add(time.Duration(day) * 24 * time.Hour).UnixMilli(),
+			V:  m.SupplyAt(float64(day) / daysPerYear),
+		})
+	}
+	return
+}
+
+// PriceFactor returns how much the price has to change by the given day
+// for the market cap to stay constant as new tokens are emitted.
+// Implements PriceScenario.
+func (m *EmissionModel) PriceFactor(day int) float64 {
+	return m.Circulating / m.SupplyAt(float64(day)/daysPerYear)
+}
+
+// ImpliedPrices returns the daily price path that keeps the market cap
+// constant at startingPrice x circulating supply.
+func (m *EmissionModel) ImpliedPrices(startingPrice float64, days int) (ts timeseries.Series) {
+	for _, s := range m.SupplySeries(days) {
+		ts = append(ts, timeseries.ValueAt{
+			TS: s.TS,
+			V:  startingPrice * m.Circulating / s.V,
+		})
+	}
+	return
+}
+
+// DilutionPct returns the percentage by which the value of a holding
+// shrinks over the given number of days at a constant market cap, e.g.
+// the share of farm rewards paid in the token that emissions eat up.
+func (m *EmissionModel) DilutionPct(days int) float64 {
+	return (1 - m.PriceFactor(days)) * 100
+}
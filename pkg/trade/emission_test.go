@@ -0,0 +1,118 @@
+package trade
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/anrid/traderbot/pkg/coingecko"
+	"github.com/anrid/traderbot/pkg/messari"
+	"github.com/anrid/traderbot/pkg/timeseries"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmissionModel(t *testing.T) {
+	r := require.New(t)
+
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	a := &messari.Asset{Symbol: "INF"}
+	a.Metrics.Supply.Circulating = 1_000_000
+
+	// No data: flat supply.
+	m, err := NewEmissionModel(a, start)
+	r.NoError(err)
+	r.Equal(1_000_000.0, m.SupplyAt(5))
+
+	// Only inflation: compounds forever.
+	a.Metrics.Supply.AnnualInflationPct = 10
+	m, err = NewEmissionModel(a, start)
+	r.NoError(err)
+	r.InDelta(1_100_000, m.SupplyAt(1), 1e-6)
+	r.InDelta(1_210_000, m.SupplyAt(2), 1e-6)
+	r.InDelta(1_210_000, m.Supply(start.Add(2*daysPerYear*24*time.Hour)), 1e-6)
+
+	// Inflation and Y+10 (which is less than 10 years at 10%): inflation
+	// starts at 10% and decays to reach Y+10 exactly.
+	a.Metrics.Supply.YPlus10 = 2_000_000
+	m, err = NewEmissionModel(a, start)
+	r.NoError(err)
+	r.InDelta(2_000_000, m.SupplyAt(10), 1e-3)
+	r.Greater(m.decay, 0.0)
+
+	initialRate := (m.SupplyAt(0.001)/m.SupplyAt(0) - 1) / 0.001
+	r.InDelta(math.Log(1.1), initialRate, 1e-3)
+
+	// Supply stays flat after the last anchor.
+	r.InDelta(2_000_000, m.SupplyAt(30), 1e-3)
+
+	// Y2050 is reached at a constant rate after Y+10.
+	a.Metrics.Supply.Y2050 = 3_000_000
+	m, err = NewEmissionModel(a, start)
+	r.NoError(err)
+	years2050 := time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC).Sub(start).Hours() / 24 / daysPerYear
+	r.InDelta(3_000_000, m.SupplyAt(years2050), 1e-3)
+	mid := 10 + (years2050-10)/2
+	r.InDelta(2_000_000*math.Sqrt(1.5), m.SupplyAt(mid), 1e-3)
+	r.InDelta(3_000_000, m.SupplyAt(50), 1e-3)
+
+	// Y+10 barely above today at high inflation can't be fitted: constant
+	// rate to Y+10 rather than a jump at year 10.
+	a.Metrics.Supply.AnnualInflationPct = 100
+	a.Metrics.Supply.YPlus10 = 1_000_100
+	a.Metrics.Supply.Y2050 = 0
+	m, err = NewEmissionModel(a, start)
+	r.NoError(err)
+	r.False(m.fitted)
+	r.InDelta(1_000_000*math.Sqrt(1.0001), m.SupplyAt(5), 1e-3)
+	r.InDelta(1_000_100, m.SupplyAt(9.999), 0.1)
+	r.InDelta(1_000_100, m.SupplyAt(10), 1e-3)
+	a.Metrics.Supply.YPlus10 = 2_000_000
+
+	// Y+10 without inflation: constant rate.
+	a.Metrics.Supply.AnnualInflationPct = 0
+	a.Metrics.Supply.Y2050 = 0
+	m, err = NewEmissionModel(a, start)
+	r.NoError(err)
+	r.InDelta(1_000_000*math.Sqrt(2), m.SupplyAt(5), 1e-3)
+
+	a.Metrics.Supply.Circulating = 0
+	_, err = NewEmissionModel(a, start)
+	r.EqualError(err, "no circulating supply for INF")
+}
+
+func TestEmissionModelPrices(t *testing.T) {
+	r := require.New(t)
+
+	start := timeseries.ToTime(timeseries.ToDate(time.Now()))
+
+	m := &EmissionModel{
+		Symbol:             "INF",
+		Start:              start,
+		Circulating:        1_000_000,
+		AnnualInflationPct: 100,
+	}
+	r.NoError(m.Init())
+
+	// Market cap stays constant.
+	prices := m.ImpliedPrices(2.0, 365)
+	supply := m.SupplySeries(365)
+	r.Len(prices, 366)
+	for i := range prices {
+		r.InDelta(2_000_000, prices[i].V*supply[i].V, 1e-6)
+	}
+	r.InDelta(1.0, prices[len(prices)-1].V, 1e-2)
+	r.InDelta(50, m.DilutionPct(365), 0.1)
+
+	// Plugged into a forecast as a price scenario.
+	fc := NewForecast(coingecko.USD, 10_000.0, 10)
+	flat := fc.CreateMarket("Inflationary", "INF", 2.0, nil)
+	diluted := fc.CreateMarket("Inflationary", "INF", 2.0, nil, m)
+
+	r.Len(diluted.Prices, 11)
+	for i := range diluted.Prices {
+		r.Equal(flat.Prices[i].TS, diluted.Prices[i].TS)
+		r.InDelta(2.0*m.PriceFactor(i), diluted.Prices[i].V, 1e-9)
+	}
+	r.Less(diluted.Prices[10].V, flat.Prices[10].V)
+}
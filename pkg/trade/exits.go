@@ -0,0 +1,193 @@
+package trade
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/anrid/traderbot/pkg/coingecko"
+	"github.com/anrid/traderbot/pkg/timeseries"
+	"github.com/pkg/errors"
+)
+
+// Exits are protective exits that sell a position opened by a strategy,
+// see NewExitStrategy. Percentages are expressed as float64s, e.g. 10.0 for
+// 10%, and zero values are disabled.
+type Exits struct {
+	StopLossPct     float64    // Sell below a percentage of the entry price
+	StopLossATR     float64    // Sell below a multiple of the ATR at entry under the entry price
+	ATR             *Indicator // ATR of the traded market, required by StopLossATR
+	TakeProfitPct   float64    // Sell above a percentage of the entry price
+	TrailingStopPct float64    // Sell below a percentage of the highest price since entry
+	MaxDays         int        // Sell at the close after holding this many days
+}
+
+func (e Exits) Enabled() bool {
+	return e.StopLossPct > 0 || e.StopLossATR > 0 || e.TakeProfitPct > 0 || e.TrailingStopPct > 0 || e.MaxDays > 0
+}
+
+func (e Exits) String() string {
+	var parts []string
+	if e.StopLossPct > 0 {
+		parts = append(parts, fmt.Sprintf("SL %g%%", e.StopLossPct))
+	}
+	if e.StopLossATR > 0 {
+		parts = append(parts, fmt.Sprintf("SL %gx ATR", e.StopLossATR))
+	}
+	if e.TakeProfitPct > 0 {
+		parts = append(parts, fmt.Sprintf("TP %g%%", e.TakeProfitPct))
+	}
+	if e.TrailingStopPct > 0 {
+		parts = append(parts, fmt.Sprintf("TS %g%%", e.TrailingStopPct))
+	}
+	if e.MaxDays > 0 {
+		parts = append(parts, fmt.Sprintf("%d days", e.MaxDays))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ExitStrategy wraps a strategy, passing on its orders and adding
+// protective exits to the positions it opens.
+type ExitStrategy struct {
+	Strategy Strategy
+	Exits    Exits
+
+	candles   map[string]timeseries.Candle // By date
+	closeOnly bool                         // Candles are approximated from closing prices
+
+	inPosition bool
+	entryTS    int64
+	stop       float64 // Highest of the fixed and ATR stop losses
+	target     float64
+	peak       float64 // Highest price since entry, before the current day
+}
+
+// NewExitStrategy wraps a strategy with exits, checked against the daily
+// candles of the traded market, see Market.Candles. A stop is hit when the
+// low of a day reaches it, filling at the stop price, or at the open when
+// the market opens below it. Take-profit targets fill the same way above
+// the high. When both are hit on the same day the stop is assumed to be hit
+// first. Without OHLC candles a day is only known by its close, so exits
+// fill at the close when it's past the stop or target.
+//
+// Positions open at the close of the day the strategy buys, or at the
+// price of its bar on days without a candle, and close in full. Exits
+// aren't checked on days without a candle. Sells from the strategy while
+// there's no position are dropped.
+func NewExitStrategy(s Strategy, exits Exits, m *coingecko.Market) (*ExitStrategy, error) {
+	if exits.StopLossPct < 0 || exits.StopLossPct >= 100 || exits.StopLossATR < 0 || exits.TakeProfitPct < 0 ||
+		exits.TrailingStopPct < 0 || exits.TrailingStopPct >= 100 || exits.MaxDays < 0 {
+		return nil, errors.Errorf("invalid exits: %+v", exits)
+	}
+	if exits.StopLossATR > 0 && exits.ATR == nil {
+		return nil, errors.New("an ATR indicator is required for an ATR stop loss")
+	}
+	if len(m.Prices) == 0 {
+		return nil, errors.Errorf("no prices for %s to check exits against", m.ID)
+	}
+
+	e := &ExitStrategy{
+		Strategy:  s,
+		Exits:     exits,
+		candles:   make(map[string]timeseries.Candle),
+		closeOnly: len(m.OHLC) == 0,
+	}
+	for _, c := range m.Candles() {
+		e.candles[c.Date()] = c
+	}
+	return e, nil
+}
+
+func (e *ExitStrategy) Name() string {
+	return fmt.Sprintf("%s (%s)", e.Strategy.Name(), e.Exits)
+}
+
+func (e *ExitStrategy) Next(bar Bar) ([]Order, error) {
+	if bar.Index == 0 {
+		e.inPosition = false
+	}
+
+	c, found := e.candles[bar.Date()]
+
+	var orders []Order
+	if e.inPosition && found {
+		if o, exit := e.exit(c); exit {
+			orders = append(orders, o)
+			e.inPosition = false
+		} else {
+			e.peak = math.Max(e.peak, c.High)
+		}
+	}
+
+	inner, err := e.Strategy.Next(bar)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, o := range inner {
+		switch {
+		case o.Side == Sell && !e.inPosition:
+			// Already stopped out.
+			continue
+		case o.Side == Sell && o.Size >= 100.0:
+			e.inPosition = false
+		case o.Side == Buy && !e.inPosition:
+			if !found {
+				// No candle for the day, e.g. OHLC that doesn't cover
+				// it, so enter at the strategy's price.
+				c = timeseries.Candle{TS: bar.TS, Open: bar.Price, High: bar.Price, Low: bar.Price, Close: bar.Price}
+			}
+			e.enter(c)
+		}
+		orders = append(orders, o)
+	}
+
+	return orders, nil
+}
+
+func (e *ExitStrategy) enter(c timeseries.Candle) {
+	e.inPosition = true
+	e.entryTS = c.TS
+	e.peak = c.Close
+	e.stop, e.target = 0, 0
+
+	if e.Exits.StopLossPct > 0 {
+		e.stop = c.Close * (1 - e.Exits.StopLossPct/100)
+	}
+	if atr := e.Exits.ATR; e.Exits.StopLossATR > 0 && atr != nil {
+		if v, found := atr.ByTimestamp[c.TS]; found {
+			e.stop = math.Max(e.stop, c.Close-e.Exits.StopLossATR*v)
+		}
+	}
+	if e.Exits.TakeProfitPct > 0 {
+		e.target = c.Close * (1 + e.Exits.TakeProfitPct/100)
+	}
+}
+
+// exit returns a sell order if the day hits an exit.
+func (e *ExitStrategy) exit(c timeseries.Candle) (Order, bool) {
+	stop := e.stop
+	if e.Exits.TrailingStopPct > 0 {
+		stop = math.Max(stop, e.peak*(1-e.Exits.TrailingStopPct/100))
+	}
+
+	if stop > 0 && c.Low <= stop {
+		if e.closeOnly {
+			stop = math.Min(stop, c.Close)
+		} else if c.Open > 0 && c.Open < stop {
+			// Gapped down.
+			stop = c.Open
+		}
+		return Order{Side: Sell, Size: 100.0, Price: stop}, true
+	}
+	if e.target > 0 && c.High >= e.target {
+		if e.closeOnly {
+			return Order{Side: Sell, Size: 100.0, Price: math.Max(c.Close, e.target)}, true
+		}
+		return Order{Side: Sell, Size: 100.0, Price: math.Max(c.Open, e.target)}, true
+	}
+	if e.Exits.MaxDays > 0 && timeseries.DiffDays(timeseries.FromTSToDate(e.entryTS), c.Date()) >= e.Exits.MaxDays {
+		return Order{Side: Sell, Size: 100.0}, true
+	}
+	return Order{}, false
+}
@@ -0,0 +1,163 @@
+package trade
+
+import (
+	"testing"
+
+	"github.com/anrid/traderbot/pkg/coingecko"
+	"github.com/anrid/traderbot/pkg/timeseries"
+	"github.com/stretchr/testify/require"
+)
+
+// runExits buys on the first day of candles given as [open, high, low,
+// close] and returns the trades with exits.
+func runExits(r *require.Assertions, exits Exits, ohlc ...[]float64) []*Trade {
+	_, days := dailyPrices(make([]float64, len(ohlc))...)
+
+	var cs timeseries.Candles
+	for i, c := range ohlc {
+		cs = append(cs, timeseries.Candle{TS: days[i], Open: c[0], High: c[1], Low: c[2], Close: c[3]})
+	}
+	m := &coingecko.Market{ID: "test", Prices: cs.Closes(), OHLC: cs}
+
+	s := &scriptedStrategy{orders: map[int][]Order{
+		0: {{Side: Buy, Size: 100}},
+		3: {{Side: Sell, Size: 100}},
+	}}
+	e, err := NewExitStrategy(s, exits, m)
+	r.NoError(err)
+
+	trades, err := RunStrategy(e, m, m)
+	r.NoError(err)
+	r.Equal(Buy, trades[0].Side)
+	r.Equal(cs[0].Date(), trades[0].Date)
+
+	// Runs again from scratch.
+	again, err := RunStrategy(e, m, m)
+	r.NoError(err)
+	r.Equal(trades, again)
+
+	return trades
+}
+
+func TestExitStrategy(t *testing.T) {
+	r := require.New(t)
+
+	entry := []float64{100, 100, 100, 100}
+
+	// Stop loss at 90, hit on day 3.
+	trades := runExits(r, Exits{StopLossPct: 10}, entry, []float64{98, 101, 95, 97}, []float64{96, 96, 85, 88}, []float64{88, 90, 87, 89})
+	r.Len(trades, 2) // The strategy's sell on day 4 is dropped.
+	r.Equal(Sell, trades[1].Side)
+	r.Equal("2021-01-03", trades[1].Date)
+	r.Equal(90.0, trades[1].Price)
+
+	// Gapped down below the stop.
+	trades = runExits(r, Exits{StopLossPct: 10}, entry, []float64{80, 82, 78, 81})
+	r.Equal(80.0, trades[1].Price)
+
+	// Take profit at 120, and gapped up above it.
+	trades = runExits(r, Exits{StopLossPct: 10, TakeProfitPct: 20}, entry, []float64{105, 125, 104, 110})
+	r.Equal(120.0, trades[1].Price)
+	trades = runExits(r, Exits{TakeProfitPct: 20}, entry, []float64{130, 135, 128, 131})
+	r.Equal(130.0, trades[1].Price)
+
+	// Both hit on the same day: the stop comes first.
+	trades = runExits(r, Exits{StopLossPct: 10, TakeProfitPct: 20}, entry, []float64{100, 125, 85, 110})
+	r.Equal(90.0, trades[1].Price)
+
+	// Trailing 10% below the high of 130 on day 2.
+	trades = runExits(r, Exits{TrailingStopPct: 10}, entry, []float64{100, 130, 95, 125}, []float64{120, 121, 115, 116})
+	r.Equal("2021-01-03", trades[1].Date)
+	r.Equal(117.0, trades[1].Price)
+
+	// Sold at the close after 2 days.
+	trades = runExits(r, Exits{MaxDays: 2}, entry, []float64{100, 110, 95, 105}, []float64{105, 108, 104, 106})
+	r.Equal("2021-01-03", trades[1].Date)
+	r.Equal(106.0, trades[1].Price)
+
+	// 2 x ATR of 2.5 below the entry.
+	_, days := dailyPrices(0)
+	atr := newIndicator("ATR", timeseries.Series{{TS: days[0], V: 2.5}})
+	trades = runExits(r, Exits{StopLossPct: 10, StopLossATR: 2, ATR: atr}, entry, []float64{98, 101, 95, 97})
+	r.Equal(95.0, trades[1].Price)
+
+	// The strategy's own exit.
+	trades = runExits(r, Exits{StopLossPct: 10}, entry, entry, entry, entry)
+	r.Len(trades, 2)
+	r.Equal("2021-01-04", trades[1].Date)
+	r.Equal(100.0, trades[1].Price)
+
+	// Bars on days the traded market has no candle for, e.g. a tracked
+	// market with a longer history: the buy still opens a position, at the
+	// bar's price, so exits and the strategy's sells aren't lost.
+	_, days = dailyPrices(0, 0, 0, 0)
+	next := func(closes ...float64) (sells []Order) {
+		prices, _ := dailyPrices(append([]float64{0}, closes...)...)
+		m := &coingecko.Market{ID: "test", Prices: prices[1:]}
+
+		s := &scriptedStrategy{orders: map[int][]Order{
+			0: {{Side: Buy, Size: 100}},
+			3: {{Side: Sell, Size: 100}},
+		}}
+		e, err := NewExitStrategy(s, Exits{StopLossPct: 10}, m)
+		r.NoError(err)
+
+		for i, ts := range days {
+			orders, err := e.Next(Bar{Index: i, TS: ts, Price: 100})
+			r.NoError(err)
+			for _, o := range orders {
+				if o.Side == Sell {
+					sells = append(sells, o)
+				}
+			}
+		}
+		return
+	}
+	r.Equal([]Order{{Side: Sell, Size: 100}}, next(100, 100, 100))
+	r.Equal([]Order{{Side: Sell, Size: 100, Price: 85}}, next(100, 85, 85))
+
+	m := &coingecko.Market{ID: "test", Prices: timeseries.Series{{}}}
+	e, err := NewExitStrategy(&scriptedStrategy{}, Exits{StopLossPct: 10, TakeProfitPct: 20}, m)
+	r.NoError(err)
+	r.Equal("scripted (SL 10%, TP 20%)", e.Name())
+
+	_, err = NewExitStrategy(&scriptedStrategy{}, Exits{StopLossPct: 100}, m)
+	r.Error(err)
+	_, err = NewExitStrategy(&scriptedStrategy{}, Exits{StopLossATR: 2}, m)
+	r.Error(err)
+	_, err = NewExitStrategy(&scriptedStrategy{}, Exits{StopLossPct: 10}, &coingecko.Market{ID: "empty"})
+	r.EqualError(err, "no prices for empty to check exits against")
+}
+
+func TestExitStrategyCloseOnly(t *testing.T) {
+	r := require.New(t)
+
+	run := func(exits Exits, closes ...float64) []*Trade {
+		prices, _ := dailyPrices(closes...)
+		m := &coingecko.Market{ID: "test", Prices: prices}
+
+		s := &scriptedStrategy{orders: map[int][]Order{0: {{Side: Buy, Size: 100}}}}
+		e, err := NewExitStrategy(s, exits, m)
+		r.NoError(err)
+
+		trades, err := RunStrategy(e, m, m)
+		r.NoError(err)
+		r.Len(trades, 2)
+		return trades
+	}
+
+	// A crash through the stop at 90 is only known at the close.
+	trades := run(Exits{StopLossPct: 10}, 100, 98, 50, 55)
+	r.Equal("2021-01-03", trades[1].Date)
+	r.Equal(50.0, trades[1].Price)
+
+	// Closing exactly at the stop.
+	trades = run(Exits{StopLossPct: 10}, 100, 90, 80)
+	r.Equal("2021-01-02", trades[1].Date)
+	r.Equal(90.0, trades[1].Price)
+
+	// A jump through the target at 120.
+	trades = run(Exits{TakeProfitPct: 20}, 100, 110, 130)
+	r.Equal("2021-01-03", trades[1].Date)
+	r.Equal(130.0, trades[1].Price)
+}
@@ -135,7 +135,15 @@ type PriceChange struct {
 	DecDays int     // Number of days that the price decreases
 }
 
-func (fc *Forecast) CreateMarket(name, symbol string, startingPrice float64, changes []PriceChange) *coingecko.Market {
+// PriceScenario adjusts the prices of a market created by CreateMarket,
+// e.g. to account for dilution from token emissions (see EmissionModel).
+type PriceScenario interface {
+	// PriceFactor returns the factor to multiply the price on the given
+	// day (0 = start date) with.
+	PriceFactor(day int) float64
+}
+
+func (fc *Forecast) CreateMarket(name, symbol string, startingPrice float64, changes []PriceChange, scenarios ...PriceScenario) *coingecko.Market {
 	m := &coingecko.Market{
 		Currency: fc.Currency,
 		ID:       symbol,
@@ -194,9 +202,16 @@ func (fc *Forecast) CreateMarket(name, symbol string, startingPrice float64, cha
 			}
 		}
 
+		v := price
+		for _, s := range scenarios {
+			// Applied after rounding, so that small daily changes (e.g.
+			// from emissions) aren't rounded away.
+			v *= s.PriceFactor(day)
+		}
+
 		m.Prices = append(m.Prices, timeseries.ValueAt{
 			TS: date.UnixMilli(),
-			V:  price,
+			V:  v,
 		})
 	}
 
@@ -3,16 +3,38 @@ package trade
 import (
 	"fmt"
 	"log"
+	"math"
+	"strings"
 
 	"github.com/anrid/traderbot/pkg/timeseries"
+	"github.com/pkg/errors"
 )
 
+// Indicator holds one or more named outputs calculated from an input
+// series, e.g. a single "value" for an EMA or "line", "signal" and "hist"
+// for MACD. ByTimestamp and ByDateString hold the first (main) output.
 type Indicator struct {
 	Name         string `json:"name"`
 	ByTimestamp  map[int64]float64
 	ByDateString map[string]float64
+	Outputs      []Output `json:"outputs"`
+	WarmUp       int      `json:"warm_up"` // Number of input entries before the first value
+
+	stream  stepper // Set for indicators that support Update, see stream.go
+	lastTS  int64
+	updated bool
+}
+
+type Output struct {
+	Name   string            `json:"name"`
+	Values timeseries.Series `json:"values"`
 }
 
+const (
+	// ValueOutput is the name of the output of single-output indicators.
+	ValueOutput = "value"
+)
+
 func (i *Indicator) ForTimestamp(milli int64) (ema float64) {
 	ema = i.ByTimestamp[milli]
 	return
@@ -23,19 +45,115 @@ func (i *Indicator) ForDate(date string) (ema float64) {
 	return
 }
 
-func NewEMAIndicator(days int, prices timeseries.Series) *Indicator {
-	// Dump(prices)
+// Series returns the main output.
+func (i *Indicator) Series() timeseries.Series {
+	return i.Outputs[0].Values
+}
 
+// OutputNames returns the names of all outputs, main output first.
+func (i *Indicator) OutputNames() (names []string) {
+	for _, o := range i.Outputs {
+		names = append(names, o.Name)
+	}
+	return
+}
+
+// Output returns a single output as an indicator of its own, e.g.
+// macd.Output("signal").
+func (i *Indicator) Output(name string) (*Indicator, error) {
+	for _, o := range i.Outputs {
+		if o.Name == name {
+			if len(i.Outputs) == 1 {
+				return i, nil
+			}
+			out := newIndicator(i.Name+"."+name, o.Values)
+			out.WarmUp = i.WarmUp
+			return out, nil
+		}
+	}
+	return nil, errors.Errorf("indicator %s has no output `%s`, expected one of: %s", i.Name, name, strings.Join(i.OutputNames(), ", "))
+}
+
+// Apply calculates another indicator using the main output of this one as
+// input, e.g. a 9-day SMA of the RSI:
+//
+// rsi.Apply(func(s timeseries.Series) (*Indicator, error) { return NewSMAIndicator(9, s) })
+//
+// The warm-up periods of both indicators add up.
+func (i *Indicator) Apply(f func(timeseries.Series) (*Indicator, error)) (*Indicator, error) {
+	out, err := f(i.Series())
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not apply indicator to %s", i.Name)
+	}
+	out.Name = out.Name + " of " + i.Name
+	out.WarmUp += i.WarmUp
+	return out, nil
+}
+
+func newIndicator(name string, values timeseries.Series) *Indicator {
+	return newMultiIndicator(name, Output{ValueOutput, values})
+}
+
+func newMultiIndicator(name string, outputs ...Output) *Indicator {
 	in := &Indicator{
-		Name:         fmt.Sprintf("%d-Day EMA", days),
+		Name:         name,
 		ByTimestamp:  make(map[int64]float64),
 		ByDateString: make(map[string]float64),
+		Outputs:      outputs,
+	}
+
+	for _, v := range outputs[0].Values {
+		in.ByTimestamp[v.TS] = v.V
+		in.ByDateString[v.Date()] = v.V
+	}
+
+	return in
+}
+
+// withWarmUp sets the warm-up period from the input the indicator was
+// calculated from.
+func (i *Indicator) withWarmUp(input timeseries.Series) *Indicator {
+	i.WarmUp = len(input)
+	if main := i.Series(); len(main) > 0 {
+		for n, v := range input {
+			if v.TS >= main[0].TS {
+				i.WarmUp = n
+				break
+			}
+		}
 	}
+	return i
+}
+
+func NewEMAIndicator(days int, prices timeseries.Series) *Indicator {
+	in, err := newEMAIndicator(days, prices)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return in
+}
 
-	if days == 0 || len(prices) < days {
+func newEMAIndicator(days int, prices timeseries.Series) (*Indicator, error) {
+	ema, err := emaSeries(days, prices)
+	if err != nil {
+		return nil, err
+	}
+	return newIndicator(fmt.Sprintf("%d-Day EMA", days), ema).withWarmUp(prices), nil
+}
+
+func checkObservationPeriod(days int, prices timeseries.Series) error {
+	if days <= 0 || len(prices) < days {
 		// Not enough days of price data to calculate desired
 		// observation period.
-		log.Fatalf("not enough price data (%d entries) for observation period (%d days)", len(prices), days)
+		return errors.Errorf("not enough price data (%d entries) for observation period (%d days)", len(prices), days)
+	}
+	return nil
+}
+
+func emaSeries(days int, prices timeseries.Series) (timeseries.Series, error) {
+	err := checkObservationPeriod(days, prices)
+	if err != nil {
+		return nil, err
 	}
 
 	// EMA calculation:
@@ -64,19 +182,207 @@ func NewEMAIndicator(days int, prices timeseries.Series) *Indicator {
 	sma := total / float64(days)
 	prevDayEMA := sma
 
+	var out timeseries.Series
 	for ; i < len(prices); i++ {
 		cur := prices[i]
 
 		ema := (cur.V * multiplier) + (prevDayEMA * (1 - multiplier))
 
-		// from := prices[i-days]
-		// fmt.Printf("[%s - %s] ema = %.04f  closing = %.04f\n", from.TimeString(), cur.TimeString(), ema, cur.V)
-
-		in.ByTimestamp[cur.TS] = ema
-		in.ByDateString[cur.Date()] = ema
+		out = append(out, timeseries.ValueAt{TS: cur.TS, V: ema})
 
 		prevDayEMA = ema
 	}
 
-	return in
+	return out, nil
+}
+
+// NewSMAIndicator returns the Simple Moving Average, i.e. the mean price
+// over the observation period.
+func NewSMAIndicator(days int, prices timeseries.Series) (*Indicator, error) {
+	sma, err := smaSeries(days, prices)
+	if err != nil {
+		return nil, err
+	}
+	return newIndicator(fmt.Sprintf("%d-Day SMA", days), sma).withWarmUp(prices), nil
+}
+
+func smaSeries(days int, prices timeseries.Series) (timeseries.Series, error) {
+	err := checkObservationPeriod(days, prices)
+	if err != nil {
+		return nil, err
+	}
+
+	var out timeseries.Series
+	var total float64
+	for i, cur := range prices {
+		total += cur.V
+		if i >= days {
+			total -= prices[i-days].V
+		}
+		if i >= days-1 {
+			out = append(out, timeseries.ValueAt{TS: cur.TS, V: total / float64(days)})
+		}
+	}
+
+	return out, nil
+}
+
+// NewWMAIndicator returns the (linearly) Weighted Moving Average, where the
+// most recent price has weight N, the one before N-1 and so on.
+func NewWMAIndicator(days int, prices timeseries.Series) (*Indicator, error) {
+	wma, err := wmaSeries(days, prices)
+	if err != nil {
+		return nil, err
+	}
+	return newIndicator(fmt.Sprintf("%d-Day WMA", days), wma).withWarmUp(prices), nil
+}
+
+func wmaSeries(days int, prices timeseries.Series) (timeseries.Series, error) {
+	err := checkObservationPeriod(days, prices)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sum of weights 1 + 2 + ... + N.
+	weights := float64(days*(days+1)) / 2
+
+	var out timeseries.Series
+	for i := days - 1; i < len(prices); i++ {
+		var total float64
+		for j := 0; j < days; j++ {
+			total += prices[i-j].V * float64(days-j)
+		}
+		out = append(out, timeseries.ValueAt{TS: prices[i].TS, V: total / weights})
+	}
+
+	return out, nil
+}
+
+// NewHMAIndicator returns the Hull Moving Average:
+//
+// HMA = WMA(2 x WMA(N/2) - WMA(N), sqrt(N))
+//
+// which follows the price more closely than an SMA or EMA of the same
+// period.
+func NewHMAIndicator(days int, prices timeseries.Series) (*Indicator, error) {
+	if days < 2 {
+		return nil, errors.Errorf("invalid observation period (%d days) for HMA, must be at least 2 days", days)
+	}
+
+	half, err := wmaSeries(days/2, prices)
+	if err != nil {
+		return nil, err
+	}
+	full, err := wmaSeries(days, prices)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := combineSeries(func(vs ...float64) float64 { return 2*vs[0] - vs[1] }, half, full)
+
+	hma, err := wmaSeries(int(math.Round(math.Sqrt(float64(days)))), diff)
+	if err != nil {
+		return nil, errors.Wrapf(err, "not enough price data (%d entries) for HMA observation period (%d days)", len(prices), days)
+	}
+
+	return newIndicator(fmt.Sprintf("%d-Day HMA", days), hma).withWarmUp(prices), nil
+}
+
+// NewDEMAIndicator returns the Double Exponential Moving Average:
+//
+// DEMA = 2 x EMA - EMA(EMA)
+func NewDEMAIndicator(days int, prices timeseries.Series) (*Indicator, error) {
+	e1, err := emaSeries(days, prices)
+	if err != nil {
+		return nil, err
+	}
+	e2, err := emaSeries(days, e1)
+	if err != nil {
+		return nil, errors.Wrapf(err, "not enough price data (%d entries) for DEMA observation period (%d days)", len(prices), days)
+	}
+
+	dema := combineSeries(func(vs ...float64) float64 { return 2*vs[0] - vs[1] }, e1, e2)
+
+	return newIndicator(fmt.Sprintf("%d-Day DEMA", days), dema).withWarmUp(prices), nil
+}
+
+// NewTEMAIndicator returns the Triple Exponential Moving Average:
+//
+// TEMA = 3 x EMA - 3 x EMA(EMA) + EMA(EMA(EMA))
+func NewTEMAIndicator(days int, prices timeseries.Series) (*Indicator, error) {
+	e1, err := emaSeries(days, prices)
+	if err != nil {
+		return nil, err
+	}
+	e2, err := emaSeries(days, e1)
+	if err != nil {
+		return nil, errors.Wrapf(err, "not enough price data (%d entries) for TEMA observation period (%d days)", len(prices), days)
+	}
+	e3, err := emaSeries(days, e2)
+	if err != nil {
+		return nil, errors.Wrapf(err, "not enough price data (%d entries) for TEMA observation period (%d days)", len(prices), days)
+	}
+
+	tema := combineSeries(func(vs ...float64) float64 { return 3*vs[0] - 3*vs[1] + vs[2] }, e1, e2, e3)
+
+	return newIndicator(fmt.Sprintf("%d-Day TEMA", days), tema).withWarmUp(prices), nil
+}
+
+// NewVWMAIndicator returns the Volume Weighted Moving Average, i.e. the
+// mean price over the observation period weighted by traded volume, e.g.
+// using Market.TotalVolumes. Prices without a volume on the same
+// timestamp are skipped, and so are days without any volume over the
+// observation period.
+func NewVWMAIndicator(days int, prices, volumes timeseries.Series) (*Indicator, error) {
+	pvs := combineSeries(func(vs ...float64) float64 { return vs[0] * vs[1] }, prices, volumes)
+	vs := combineSeries(func(vs ...float64) float64 { return vs[1] }, prices, volumes)
+
+	sumPV, err := smaSeries(days, pvs)
+	if err != nil {
+		return nil, err
+	}
+	sumV, err := smaSeries(days, vs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Windows without any volume have no weighted mean.
+	var traded timeseries.Series
+	for _, v := range sumV {
+		if v.V != 0 {
+			traded = append(traded, v)
+		}
+	}
+
+	vwma := combineSeries(func(vs ...float64) float64 { return vs[0] / vs[1] }, sumPV, traded)
+
+	return newIndicator(fmt.Sprintf("%d-Day VWMA", days), vwma).withWarmUp(prices), nil
+}
+
+// combineSeries applies f to the values of the given series on every
+// timestamp found in all of them, in the order of the first series.
+func combineSeries(f func(vs ...float64) float64, series ...timeseries.Series) (out timeseries.Series) {
+	byTS := make([]map[int64]float64, len(series))
+	for i, s := range series {
+		byTS[i] = make(map[int64]float64, len(s))
+		for _, v := range s {
+			byTS[i][v.TS] = v.V
+		}
+	}
+
+	vs := make([]float64, len(series))
+	for _, cur := range series[0] {
+		found := true
+		for i := range series {
+			vs[i], found = byTS[i][cur.TS]
+			if !found {
+				break
+			}
+		}
+		if found {
+			out = append(out, timeseries.ValueAt{TS: cur.TS, V: f(vs...)})
+		}
+	}
+
+	return
 }
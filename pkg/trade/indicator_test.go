@@ -37,3 +37,195 @@ func TestEMAIndicator(t *testing.T) {
 	r.Equal(3.0, i.ForTimestamp(day4))
 	r.Equal(4.0, i.ForTimestamp(day5))
 }
+
+func dailyPrices(vs ...float64) (timeseries.Series, []int64) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var s timeseries.Series
+	var days []int64
+	for i, v := range vs {
+		ts := start.Add(time.Duration(i) * 24 * time.Hour).UnixMilli()
+		s = append(s, timeseries.ValueAt{TS: ts, V: v})
+		days = append(days, ts)
+	}
+	return s, days
+}
+
+func TestSMAIndicator(t *testing.T) {
+	r := require.New(t)
+
+	prices, days := dailyPrices(1, 2, 3, 4, 5)
+
+	i, err := NewSMAIndicator(3, prices)
+	r.NoError(err)
+	r.Equal("3-Day SMA", i.Name)
+
+	r.NotContains(i.ByTimestamp, days[0])
+	r.NotContains(i.ByTimestamp, days[1])
+	r.Equal(2.0, valueAt(r, i, days[2]))
+	r.Equal(3.0, valueAt(r, i, days[3]))
+	r.Equal(4.0, valueAt(r, i, days[4]))
+
+	_, err = NewSMAIndicator(6, prices)
+	r.Error(err)
+}
+
+func TestWMAIndicator(t *testing.T) {
+	r := require.New(t)
+
+	prices, days := dailyPrices(1, 2, 3, 4, 5)
+
+	i, err := NewWMAIndicator(3, prices)
+	r.NoError(err)
+
+	// (1x1 + 2x2 + 3x3) / 6 = 14/6
+	r.NotContains(i.ByTimestamp, days[1])
+	r.InDelta(14.0/6, valueAt(r, i, days[2]), 1e-9)
+	r.InDelta(20.0/6, valueAt(r, i, days[3]), 1e-9)
+	r.InDelta(26.0/6, valueAt(r, i, days[4]), 1e-9)
+}
+
+func TestHMAIndicator(t *testing.T) {
+	r := require.New(t)
+
+	// The HMA has no lag on a linear trend.
+	prices, days := dailyPrices(1, 2, 3, 4, 5, 6, 7)
+
+	i, err := NewHMAIndicator(4, prices)
+	r.NoError(err)
+
+	r.NotContains(i.ByTimestamp, days[3])
+	r.InDelta(5.0, valueAt(r, i, days[4]), 1e-9)
+	r.InDelta(6.0, valueAt(r, i, days[5]), 1e-9)
+	r.InDelta(7.0, valueAt(r, i, days[6]), 1e-9)
+
+	// WMA(2) = [_, 2.3333, 3.0, 2.3333, 3.3333], WMA(4) = [_, _, _, 2.4, 3.1]
+	// 2 x WMA(2) - WMA(4) = [2.2667, 3.5667]
+	// HMA = (2.2667 + 2 x 3.5667) / 3 = 3.1333
+	prices, days = dailyPrices(1, 3, 3, 2, 4)
+
+	i, err = NewHMAIndicator(4, prices)
+	r.NoError(err)
+	r.InDelta(9.4/3, valueAt(r, i, days[4]), 1e-9)
+
+	_, err = NewHMAIndicator(1, prices)
+	r.Error(err)
+	_, err = NewHMAIndicator(5, prices)
+	r.Error(err)
+}
+
+func TestDEMAAndTEMAIndicators(t *testing.T) {
+	r := require.New(t)
+
+	// EMA(2)           = [_, _, 2.5, 3.5, 4.5, 5.5, 6.5, 7.5]
+	// EMA(EMA(2))      = [_, _, _, _, 4.0, 5.0, 6.0, 7.0]
+	// EMA(EMA(EMA(2))) = [_, _, _, _, _, _, 5.5, 6.5]
+	prices, days := dailyPrices(1, 2, 3, 4, 5, 6, 7, 8)
+
+	dema, err := NewDEMAIndicator(2, prices)
+	r.NoError(err)
+
+	r.NotContains(dema.ByTimestamp, days[3])
+	r.InDelta(5.0, valueAt(r, dema, days[4]), 1e-9)
+	r.InDelta(8.0, valueAt(r, dema, days[7]), 1e-9)
+
+	tema, err := NewTEMAIndicator(2, prices)
+	r.NoError(err)
+
+	r.NotContains(tema.ByTimestamp, days[5])
+	r.InDelta(7.0, valueAt(r, tema, days[6]), 1e-9)
+	r.InDelta(8.0, valueAt(r, tema, days[7]), 1e-9)
+
+	_, err = NewTEMAIndicator(3, prices)
+	r.Error(err)
+}
+
+func TestVWMAIndicator(t *testing.T) {
+	r := require.New(t)
+
+	prices, days := dailyPrices(1, 2, 3, 4, 5)
+	volumes, _ := dailyPrices(1, 1, 2, 0, 0)
+
+	i, err := NewVWMAIndicator(2, prices, volumes)
+	r.NoError(err)
+
+	r.NotContains(i.ByTimestamp, days[0])
+	r.InDelta(1.5, valueAt(r, i, days[1]), 1e-9)
+	r.InDelta(8.0/3, valueAt(r, i, days[2]), 1e-9)
+	r.InDelta(3.0, valueAt(r, i, days[3]), 1e-9)
+
+	// No volume in the window.
+	r.NotContains(i.ByTimestamp, days[4])
+}
+
+// valueAt returns the main output of an indicator on a day it must have a
+// value for.
+func valueAt(r *require.Assertions, i *Indicator, ts int64) float64 {
+	v, found := i.ByTimestamp[ts]
+	r.True(found, "%s has no value for %s", i.Name, timeseries.FromTSToDate(ts))
+	return v
+}
+
+func output(r *require.Assertions, i *Indicator, name string) *Indicator {
+	o, err := i.Output(name)
+	r.NoError(err)
+	return o
+}
+
+func TestIndicatorOutputs(t *testing.T) {
+	r := require.New(t)
+
+	prices, days := dailyPrices(1, 2, 3, 4, 5)
+
+	sma, err := NewSMAIndicator(3, prices)
+	r.NoError(err)
+
+	r.Equal([]string{ValueOutput}, sma.OutputNames())
+	r.Equal(2, sma.WarmUp)
+	r.Len(sma.Series(), 3)
+	r.Equal(sma, output(r, sma, ValueOutput))
+
+	bb, err := NewBollingerIndicator(3, 2, prices)
+	r.NoError(err)
+	r.Equal(2, bb.WarmUp)
+
+	// The main output backs ForTimestamp and ForDate.
+	r.Equal(bb.Outputs[0].Values, bb.Series())
+	r.Equal(3.0, bb.ForTimestamp(days[3]))
+	r.Equal(3.0, bb.ForDate(prices[3].Date()))
+
+	upper := output(r, bb, "upper")
+	r.Equal("BB(3,2).upper", upper.Name)
+	r.Equal(2, upper.WarmUp)
+	r.Greater(upper.ForTimestamp(days[3]), 3.0)
+
+	_, err = bb.Output("top")
+	r.EqualError(err, "indicator BB(3,2) has no output `top`, expected one of: middle, upper, lower, percent_b, bandwidth")
+}
+
+func TestIndicatorApply(t *testing.T) {
+	r := require.New(t)
+
+	// RSI(2) = [_, _, 50, 75, 87.5, 43.75]
+	prices, days := dailyPrices(1, 2, 1, 2, 3, 2)
+
+	rsi, err := NewRSIIndicator(2, prices)
+	r.NoError(err)
+	r.Equal(2, rsi.WarmUp)
+
+	sma, err := rsi.Apply(func(s timeseries.Series) (*Indicator, error) {
+		return NewSMAIndicator(2, s)
+	})
+	r.NoError(err)
+
+	r.Equal("2-Day SMA of 2-Day RSI", sma.Name)
+	r.Equal(3, sma.WarmUp)
+	r.NotContains(sma.ByTimestamp, days[2])
+	r.InDelta(62.5, valueAt(r, sma, days[3]), 1e-9)
+	r.InDelta(65.625, valueAt(r, sma, days[5]), 1e-9)
+
+	_, err = rsi.Apply(func(s timeseries.Series) (*Indicator, error) {
+		return NewSMAIndicator(5, s)
+	})
+	r.Error(err)
+}
@@ -0,0 +1,53 @@
+package trade
+
+import (
+	"fmt"
+
+	"github.com/anrid/traderbot/pkg/timeseries"
+	"github.com/pkg/errors"
+)
+
+// NewMACDIndicator returns the Moving Average Convergence Divergence for
+// the given periods, commonly 12, 26 and 9 days. Its outputs are:
+//
+// line   = EMA(fast) - EMA(slow)
+// signal = EMA(signal) of the MACD line
+// hist   = line - signal
+//
+// All three outputs have values for the same timestamps, i.e. from the
+// first day the signal line can be calculated.
+func NewMACDIndicator(fast, slow, signal int, prices timeseries.Series) (*Indicator, error) {
+	if fast <= 0 || slow <= fast || signal <= 0 {
+		return nil, errors.Errorf("invalid MACD periods (%d, %d, %d), fast must be shorter than slow", fast, slow, signal)
+	}
+	if len(prices) < slow+signal+1 {
+		return nil, errors.Errorf("not enough price data (%d entries) for MACD periods (%d, %d, %d), need %d entries",
+			len(prices), fast, slow, signal, slow+signal+1)
+	}
+
+	fastEMA, err := emaSeries(fast, prices)
+	if err != nil {
+		return nil, err
+	}
+	slowEMA, err := emaSeries(slow, prices)
+	if err != nil {
+		return nil, err
+	}
+
+	// The slow EMA starts later, so use its timestamps.
+	line := combineSeries(func(vs ...float64) float64 { return vs[1] - vs[0] }, slowEMA, fastEMA)
+
+	sig, err := emaSeries(signal, line)
+	if err != nil {
+		return nil, err
+	}
+
+	line = combineSeries(func(vs ...float64) float64 { return vs[1] }, sig, line)
+	hist := combineSeries(func(vs ...float64) float64 { return vs[0] - vs[1] }, line, sig)
+
+	return newMultiIndicator(fmt.Sprintf("MACD(%d,%d,%d)", fast, slow, signal),
+		Output{"line", line},
+		Output{"signal", sig},
+		Output{"hist", hist},
+	).withWarmUp(prices), nil
+}
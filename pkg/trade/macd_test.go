@@ -0,0 +1,95 @@
+package trade
+
+import (
+	"testing"
+
+	"github.com/anrid/traderbot/pkg/coingecko"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMACDIndicator(t *testing.T) {
+	r := require.New(t)
+
+	// EMA(2)    = [_, _, 2.5, 3.5, ...]
+	// EMA(3)    = [_, _, _, 3.0, ...]
+	// MACD line = [_, _, _, 0.5, 0.1667, -0.1111, -0.2870, -0.0540, 0.1695, 0.3169]
+	// Signal    = [_, _, _, _, _, 0.0370, -0.1790, -0.0957, 0.0811, 0.2383]
+	prices, days := dailyPrices(1, 2, 3, 4, 3, 2, 1, 2, 3, 4)
+
+	m, err := NewMACDIndicator(2, 3, 2, prices)
+	r.NoError(err)
+	r.Equal("MACD(2,3,2)", m.Name)
+
+	// Outputs are aligned on the signal line.
+	r.Equal([]string{"line", "signal", "hist"}, m.OutputNames())
+	for _, o := range m.Outputs {
+		r.Len(o.Values, 5)
+	}
+	r.Equal(5, m.WarmUp)
+	r.Equal(0.0, output(r, m, "line").ForTimestamp(days[4]))
+
+	r.InDelta(-0.111111, output(r, m, "line").ForTimestamp(days[5]), 1e-6)
+	r.InDelta(0.037037, output(r, m, "signal").ForTimestamp(days[5]), 1e-6)
+	r.InDelta(-0.148148, output(r, m, "hist").ForTimestamp(days[5]), 1e-6)
+
+	r.InDelta(0.316915, output(r, m, "line").ForTimestamp(days[9]), 1e-6)
+	r.InDelta(0.238312, output(r, m, "signal").ForTimestamp(days[9]), 1e-6)
+	r.InDelta(0.078604, output(r, m, "hist").ForTimestamp(days[9]), 1e-6)
+
+	_, err = NewMACDIndicator(3, 2, 2, prices)
+	r.Error(err)
+	_, err = NewMACDIndicator(2, 3, 7, prices)
+	r.Error(err)
+}
+
+func TestMACDStrategy(t *testing.T) {
+	r := require.New(t)
+
+	prices, _ := dailyPrices(1, 2, 3, 4, 3, 2, 1, 2, 3, 4)
+	market := &coingecko.Market{ID: "test", Prices: prices}
+
+	m, err := NewMACDIndicator(2, 3, 2, prices)
+	r.NoError(err)
+
+	// Histogram = [-0.1481, -0.1080, 0.0417, 0.0884, 0.0786]
+	s, err := NewMACDStrategy(m, MACDSignalCross)
+	r.NoError(err)
+	trades, err := RunStrategy(s, market, market)
+	r.NoError(err)
+	r.Len(trades, 1)
+	r.Equal(Buy, trades[0].Side)
+	r.Equal(prices[7].Date(), trades[0].Date)
+	r.Equal(2.0, trades[0].Price)
+
+	// MACD line = [-0.1111, -0.2870, -0.0540, 0.1695, 0.3169]
+	s, err = NewMACDStrategy(m, MACDZeroCross)
+	r.NoError(err)
+	trades, err = RunStrategy(s, market, market)
+	r.NoError(err)
+	r.Len(trades, 1)
+	r.Equal(Buy, trades[0].Side)
+	r.Equal(prices[8].Date(), trades[0].Date)
+
+	_, err = NewMACDStrategy(m, 0)
+	r.Error(err)
+
+	// Crossings landing exactly on zero.
+	line, days := dailyPrices(1, 0, -1, 0, 1, 0, 2)
+	m = newMultiIndicator("MACD", Output{"line", line}, Output{"signal", line}, Output{"hist", line})
+
+	s, err = NewMACDStrategy(m, MACDZeroCross)
+	r.NoError(err)
+
+	var sides []Side
+	var dates []int64
+	for n, v := range line {
+		orders, err := s.Next(Bar{Index: n, TS: v.TS})
+		r.NoError(err)
+		for _, o := range orders {
+			sides = append(sides, o.Side)
+			dates = append(dates, v.TS)
+		}
+	}
+	r.Equal([]Side{Sell, Buy}, sides)
+	r.Equal([]int64{days[2], days[4]}, dates)
+}
@@ -0,0 +1,201 @@
+package trade
+
+import (
+	"io"
+	"math"
+	"os"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// PeriodsPerYear is used to annualize daily returns. Crypto markets trade
+// every day of the year.
+const PeriodsPerYear = 365
+
+const dayMillis = 24 * 60 * 60 * 1000
+
+// Metrics measure the performance of a backtest. Returns, rates and
+// drawdowns are fractions, e.g. 0.25 for 25%. Ratios assume a risk-free
+// rate of 0.
+type Metrics struct {
+	TotalReturn     float64
+	CAGR            float64 // Compound annual growth rate
+	Volatility      float64 // Annualized standard deviation of daily returns
+	Sharpe          float64 // Annualized mean / standard deviation of daily returns
+	Sortino         float64 // Annualized mean / downside deviation of daily returns
+	Calmar          float64 // CAGR / max drawdown
+	MaxDrawdown     float64 // Largest drop from a peak
+	MaxDrawdownDays int     // Longest time from a peak until it's reached again (or the last day)
+	Exposure        float64 // Fraction of days holding a position
+
+	RoundTrips   int     // Closed positions, from the first buy to selling all units
+	WinRate      float64 // Fraction of profitable round trips
+	AvgWin       float64 // Mean return of profitable round trips
+	AvgLoss      float64 // Mean return of losing round trips, negative
+	ProfitFactor float64 // Gross profit / gross loss of round trips, +Inf without losses
+}
+
+// Metrics calculates the performance metrics of the result.
+func (r *BacktestResult) Metrics() *Metrics {
+	return CalculateMetrics(r.Equity, r.Fills)
+}
+
+// CalculateMetrics calculates performance metrics from a daily equity curve
+// and the fills that produced it.
+func CalculateMetrics(equity []EquityPoint, fills []*Fill) *Metrics {
+	m := new(Metrics)
+
+	if len(equity) > 0 {
+		first, last := equity[0], equity[len(equity)-1]
+
+		if first.Value > 0 {
+			m.TotalReturn = last.Value/first.Value - 1
+
+			years := float64(last.TS-first.TS) / float64(PeriodsPerYear*dayMillis)
+			if years > 0 {
+				m.CAGR = math.Pow(last.Value/first.Value, 1/years) - 1
+			}
+		}
+
+		var held int
+		for _, e := range equity {
+			if e.Units > 0 {
+				held++
+			}
+		}
+		m.Exposure = float64(held) / float64(len(equity))
+	}
+
+	// Daily returns.
+	var returns []float64
+	for i := 1; i < len(equity); i++ {
+		if prev := equity[i-1].Value; prev > 0 {
+			returns = append(returns, equity[i].Value/prev-1)
+		}
+	}
+
+	if n := float64(len(returns)); n > 1 {
+		var mean float64
+		for _, r := range returns {
+			mean += r / n
+		}
+
+		var variance, downside float64
+		for _, r := range returns {
+			variance += (r - mean) * (r - mean) / (n - 1)
+			if r < 0 {
+				downside += r * r / n
+			}
+		}
+
+		annualize := math.Sqrt(PeriodsPerYear)
+		sd := math.Sqrt(variance)
+
+		m.Volatility = sd * annualize
+		if sd > 0 {
+			m.Sharpe = mean / sd * annualize
+		}
+		if downside > 0 {
+			m.Sortino = mean / math.Sqrt(downside) * annualize
+		}
+	}
+
+	// Drawdowns.
+	var peak float64
+	var peakTS int64
+	var inDrawdown bool
+	drawdownDays := func(ts int64) {
+		if days := int((ts - peakTS) / dayMillis); days > m.MaxDrawdownDays {
+			m.MaxDrawdownDays = days
+		}
+	}
+	for i, e := range equity {
+		if i == 0 || e.Value >= peak {
+			if inDrawdown {
+				drawdownDays(e.TS)
+			}
+			peak, peakTS, inDrawdown = e.Value, e.TS, false
+			continue
+		}
+		inDrawdown = true
+		if dd := (peak - e.Value) / peak; dd > m.MaxDrawdown {
+			m.MaxDrawdown = dd
+		}
+	}
+	if inDrawdown {
+		drawdownDays(equity[len(equity)-1].TS)
+	}
+	if m.MaxDrawdown > 0 {
+		m.Calmar = m.CAGR / m.MaxDrawdown
+	}
+
+	// Round trips.
+	var spent, received, grossProfit, grossLoss float64
+	var wins, losses int
+	for _, f := range fills {
+		switch f.Trade.Side {
+		case Buy:
+			spent += f.Amount
+		case Sell:
+			received += f.Amount
+		}
+		if f.Trade.Side != Sell || f.Held > 0 || spent == 0 {
+			continue
+		}
+
+		m.RoundTrips++
+		pl := received - spent
+		ret := received/spent - 1
+		if pl > 0 {
+			wins++
+			grossProfit += pl
+			m.AvgWin += ret
+		} else if pl < 0 {
+			losses++
+			grossLoss -= pl
+			m.AvgLoss += ret
+		}
+		spent, received = 0, 0
+	}
+
+	if m.RoundTrips > 0 {
+		m.WinRate = float64(wins) / float64(m.RoundTrips)
+	}
+	if wins > 0 {
+		m.AvgWin /= float64(wins)
+	}
+	if losses > 0 {
+		m.AvgLoss /= float64(losses)
+	}
+	if grossLoss > 0 {
+		m.ProfitFactor = grossProfit / grossLoss
+	} else if grossProfit > 0 {
+		m.ProfitFactor = math.Inf(1)
+	}
+
+	return m
+}
+
+// Print prints the metrics to stdout.
+func (m *Metrics) Print() {
+	m.Fprint(os.Stdout)
+}
+
+// Fprint prints the metrics in the style of the BacktestResult summary.
+func (m *Metrics) Fprint(w io.Writer) {
+	pr := message.NewPrinter(language.English)
+
+	pr.Fprintf(w, "- Total return       : %.02f %%\n", m.TotalReturn*100)
+	pr.Fprintf(w, "- CAGR               : %.02f %%\n", m.CAGR*100)
+	pr.Fprintf(w, "- Volatility         : %.02f %%\n", m.Volatility*100)
+	pr.Fprintf(w, "- Sharpe ratio       : %.02f\n", m.Sharpe)
+	pr.Fprintf(w, "- Sortino ratio      : %.02f\n", m.Sortino)
+	pr.Fprintf(w, "- Calmar ratio       : %.02f\n", m.Calmar)
+	pr.Fprintf(w, "- Max drawdown       : %.02f %%  (%d days)\n", m.MaxDrawdown*100, m.MaxDrawdownDays)
+	pr.Fprintf(w, "- Exposure           : %.02f %%\n", m.Exposure*100)
+	pr.Fprintf(w, "- Round trips        : %d\n", m.RoundTrips)
+	pr.Fprintf(w, "- Win rate           : %.02f %%\n", m.WinRate*100)
+	pr.Fprintf(w, "- Average win / loss : %.02f %% / %.02f %%\n", m.AvgWin*100, m.AvgLoss*100)
+	pr.Fprintf(w, "- Profit factor      : %.02f\n\n\n", m.ProfitFactor)
+}
@@ -0,0 +1,104 @@
+package trade
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testEquity(units []float64, vs ...float64) (equity []EquityPoint) {
+	_, days := dailyPrices(vs...)
+	for i, v := range vs {
+		equity = append(equity, EquityPoint{TS: days[i], Value: v, Units: units[i]})
+	}
+	return
+}
+
+func TestCalculateMetrics(t *testing.T) {
+	r := require.New(t)
+
+	// Returns are 10%, -20% and 50%.
+	equity := testEquity([]float64{0, 1, 1, 0}, 100, 110, 88, 132)
+
+	m := CalculateMetrics(equity, nil)
+	r.InDelta(0.32, m.TotalReturn, 1e-9)
+	r.InEpsilon(math.Pow(1.32, 365.0/3)-1, m.CAGR, 1e-9)
+
+	// Mean = 0.4 / 3 = 0.1333
+	// Sample variance = (0.0333² + 0.3333² + 0.3667²) / 2 = 0.2467 / 2
+	// Downside deviation = sqrt(0.2² / 3)
+	mean := 0.4 / 3
+	sd := math.Sqrt((math.Pow(0.1-mean, 2) + math.Pow(-0.2-mean, 2) + math.Pow(0.5-mean, 2)) / 2)
+	r.InDelta(0.351188, sd, 1e-6)
+	r.InDelta(sd*math.Sqrt(365), m.Volatility, 1e-9)
+	r.InDelta(mean/sd*math.Sqrt(365), m.Sharpe, 1e-9)
+	r.InDelta(mean/math.Sqrt(0.04/3)*math.Sqrt(365), m.Sortino, 1e-9)
+
+	// Down 20% from the peak of 110 on day 2, recovered on day 4.
+	r.InDelta(0.2, m.MaxDrawdown, 1e-9)
+	r.Equal(2, m.MaxDrawdownDays)
+	r.InEpsilon(m.CAGR/0.2, m.Calmar, 1e-9)
+
+	r.Equal(0.5, m.Exposure)
+
+	// Not recovered by the last day.
+	m = CalculateMetrics(testEquity([]float64{1, 1, 1, 1, 1}, 100, 90, 120, 100, 60), nil)
+	r.InDelta(0.5, m.MaxDrawdown, 1e-9)
+	r.Equal(2, m.MaxDrawdownDays)
+	r.Equal(1.0, m.Exposure)
+
+	m = CalculateMetrics(nil, nil)
+	r.Equal(&Metrics{}, m)
+}
+
+func TestCalculateMetricsRoundTrips(t *testing.T) {
+	r := require.New(t)
+
+	buy, sell := &Trade{Side: Buy}, &Trade{Side: Sell}
+	fills := []*Fill{
+		// +50%
+		{Trade: buy, Amount: 100, Held: 1},
+		{Trade: sell, Amount: 150, Held: 0},
+		// -30%, sold in two parts
+		{Trade: buy, Amount: 100, Held: 1},
+		{Trade: sell, Amount: 30, Held: 0.5},
+		{Trade: sell, Amount: 40, Held: 0},
+		// +20%
+		{Trade: buy, Amount: 50, Held: 1},
+		{Trade: sell, Amount: 60, Held: 0},
+		// Still open.
+		{Trade: buy, Amount: 10, Held: 1},
+	}
+
+	m := CalculateMetrics(nil, fills)
+	r.Equal(3, m.RoundTrips)
+	r.InDelta(2.0/3, m.WinRate, 1e-9)
+	r.InDelta(0.35, m.AvgWin, 1e-9)
+	r.InDelta(-0.3, m.AvgLoss, 1e-9)
+	r.InDelta(60.0/30, m.ProfitFactor, 1e-9)
+
+	m = CalculateMetrics(nil, fills[:2])
+	r.Equal(1.0, m.WinRate)
+	r.Equal(0.0, m.AvgLoss)
+	r.True(math.IsInf(m.ProfitFactor, 1))
+}
+
+func TestBacktestResultMetrics(t *testing.T) {
+	r := require.New(t)
+
+	m, ts := testTrades(r)
+
+	// Bought at 12 and 8, sold at 16 and 20, bought back at 1,250.5.
+	metrics := ExecuteTrades("Golden", 10_000, m, ts).Metrics()
+	r.Equal(1, metrics.RoundTrips)
+	r.InDelta(0.875, metrics.AvgWin, 1e-9)
+	r.InDelta(18750/1250.5*25/10_000-1, metrics.TotalReturn, 1e-9)
+	r.InDelta(5.0/7, metrics.Exposure, 1e-9)
+
+	var b strings.Builder
+	metrics.Fprint(&b)
+	r.Contains(b.String(), "- Round trips        : 1\n")
+	r.Contains(b.String(), "- Max drawdown       : 98.00 %")
+}
@@ -0,0 +1,149 @@
+package trade
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/anrid/traderbot/pkg/timeseries"
+	"github.com/pkg/errors"
+)
+
+// NewRSIIndicator returns Wilder's Relative Strength Index (0 - 100):
+//
+// RSI = 100 - 100 / (1 + average gain / average loss)
+//
+// The first averages are the mean gain and loss over the first N price
+// changes, after which they're smoothed: avg = (prev avg x (N-1) + cur) / N.
+func NewRSIIndicator(days int, prices timeseries.Series) (*Indicator, error) {
+	rsi, err := rsiSeries(days, prices)
+	if err != nil {
+		return nil, err
+	}
+	return newIndicator(fmt.Sprintf("%d-Day RSI", days), rsi).withWarmUp(prices), nil
+}
+
+func rsiSeries(days int, prices timeseries.Series) (timeseries.Series, error) {
+	if days <= 0 || len(prices) < days+1 {
+		return nil, errors.Errorf("not enough price data (%d entries) for RSI observation period (%d days), need %d entries", len(prices), days, days+1)
+	}
+
+	n := float64(days)
+
+	var avgGain, avgLoss float64
+	var out timeseries.Series
+	for i := 1; i < len(prices); i++ {
+		change := prices[i].V - prices[i-1].V
+		gain, loss := math.Max(change, 0), math.Max(-change, 0)
+
+		if i <= days {
+			avgGain += gain / n
+			avgLoss += loss / n
+			if i < days {
+				continue
+			}
+		} else {
+			avgGain = (avgGain*(n-1) + gain) / n
+			avgLoss = (avgLoss*(n-1) + loss) / n
+		}
+
+		var rsi float64
+		switch {
+		case avgGain == 0 && avgLoss == 0:
+			rsi = 50 // Flat prices.
+		case avgLoss == 0:
+			rsi = 100
+		default:
+			rsi = 100 - 100/(1+avgGain/avgLoss)
+		}
+
+		out = append(out, timeseries.ValueAt{TS: prices[i].TS, V: rsi})
+	}
+
+	return out, nil
+}
+
+// NewStochRSIIndicator returns the Stochastic RSI (0 - 100), i.e. where the
+// RSI is within its own range over the last stochDays:
+//
+// StochRSI = (RSI - lowest RSI) / (highest RSI - lowest RSI) x 100
+//
+// A flat RSI gives 50.
+func NewStochRSIIndicator(rsiDays, stochDays int, prices timeseries.Series) (*Indicator, error) {
+	rsi, err := rsiSeries(rsiDays, prices)
+	if err != nil {
+		return nil, err
+	}
+	if stochDays <= 0 || len(rsi) < stochDays {
+		return nil, errors.Errorf("not enough price data (%d entries) for Stochastic RSI observation periods (%d and %d days), need %d entries",
+			len(prices), rsiDays, stochDays, rsiDays+stochDays)
+	}
+
+	var out timeseries.Series
+	for i := stochDays - 1; i < len(rsi); i++ {
+		lowest, highest := rangeOf(rsi[i-stochDays+1 : i+1])
+
+		v := 50.0
+		if highest > lowest {
+			v = (rsi[i].V - lowest) / (highest - lowest) * 100
+		}
+		out = append(out, timeseries.ValueAt{TS: rsi[i].TS, V: v})
+	}
+
+	return newIndicator(fmt.Sprintf("%d/%d-Day Stochastic RSI", rsiDays, stochDays), out).withWarmUp(prices), nil
+}
+
+// NewROCIndicator returns the Rate of Change, i.e. the percentage change
+// in price over the observation period. Days where the price N days
+// earlier is zero are skipped.
+func NewROCIndicator(days int, prices timeseries.Series) (*Indicator, error) {
+	if days <= 0 || len(prices) < days+1 {
+		return nil, errors.Errorf("not enough price data (%d entries) for ROC observation period (%d days), need %d entries", len(prices), days, days+1)
+	}
+
+	var out timeseries.Series
+	for i := days; i < len(prices); i++ {
+		prev := prices[i-days].V
+		if prev == 0 {
+			continue
+		}
+		out = append(out, timeseries.ValueAt{TS: prices[i].TS, V: (prices[i].V - prev) / prev * 100})
+	}
+
+	return newIndicator(fmt.Sprintf("%d-Day ROC", days), out).withWarmUp(prices), nil
+}
+
+// NewWilliamsRIndicator returns Williams %R (-100 - 0), i.e. how far the
+// price is below the highest price over the observation period:
+//
+// %R = (highest - price) / (highest - lowest) x -100
+//
+// Markets only have daily (closing) prices, so the highest and lowest
+// prices are those of the closes. A flat range gives -50.
+func NewWilliamsRIndicator(days int, prices timeseries.Series) (*Indicator, error) {
+	err := checkObservationPeriod(days, prices)
+	if err != nil {
+		return nil, err
+	}
+
+	var out timeseries.Series
+	for i := days - 1; i < len(prices); i++ {
+		lowest, highest := rangeOf(prices[i-days+1 : i+1])
+
+		v := -50.0
+		if highest > lowest {
+			v = (highest - prices[i].V) / (highest - lowest) * -100
+		}
+		out = append(out, timeseries.ValueAt{TS: prices[i].TS, V: v})
+	}
+
+	return newIndicator(fmt.Sprintf("%d-Day Williams %%R", days), out).withWarmUp(prices), nil
+}
+
+func rangeOf(s timeseries.Series) (lowest, highest float64) {
+	lowest, highest = math.Inf(1), math.Inf(-1)
+	for _, v := range s {
+		lowest = math.Min(lowest, v.V)
+		highest = math.Max(highest, v.V)
+	}
+	return
+}
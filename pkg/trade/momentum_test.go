@@ -0,0 +1,107 @@
+package trade
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRSIIndicator(t *testing.T) {
+	r := require.New(t)
+
+	// Changes:   +1, -1, +1, +1, -1
+	// Avg gain:  0.5, 0.75, 0.875, 0.4375
+	// Avg loss:  0.5, 0.25, 0.125, 0.5625
+	prices, days := dailyPrices(1, 2, 1, 2, 3, 2)
+
+	i, err := NewRSIIndicator(2, prices)
+	r.NoError(err)
+	r.Equal("2-Day RSI", i.Name)
+
+	r.NotContains(i.ByTimestamp, days[1])
+	r.InDelta(50.0, valueAt(r, i, days[2]), 1e-9)
+	r.InDelta(75.0, valueAt(r, i, days[3]), 1e-9)
+	r.InDelta(87.5, valueAt(r, i, days[4]), 1e-9)
+	r.InDelta(43.75, valueAt(r, i, days[5]), 1e-9)
+
+	_, err = NewRSIIndicator(6, prices)
+	r.Error(err)
+
+	// Only gains.
+	prices, days = dailyPrices(1, 2, 3)
+	i, err = NewRSIIndicator(2, prices)
+	r.NoError(err)
+	r.Equal(100.0, valueAt(r, i, days[2]))
+}
+
+func TestStochRSIIndicator(t *testing.T) {
+	r := require.New(t)
+
+	// RSI(2) = [_, _, 50, 75, 87.5, 43.75]
+	prices, days := dailyPrices(1, 2, 1, 2, 3, 2)
+
+	i, err := NewStochRSIIndicator(2, 2, prices)
+	r.NoError(err)
+
+	r.NotContains(i.ByTimestamp, days[2])
+	r.InDelta(100.0, valueAt(r, i, days[3]), 1e-9)
+	r.InDelta(100.0, valueAt(r, i, days[4]), 1e-9)
+	r.InDelta(0.0, valueAt(r, i, days[5]), 1e-9)
+
+	i, err = NewStochRSIIndicator(2, 3, prices)
+	r.NoError(err)
+	// (87.5 - 50) / (87.5 - 50) and (43.75 - 43.75) / (87.5 - 43.75)
+	r.InDelta(100.0, valueAt(r, i, days[4]), 1e-9)
+	r.InDelta(0.0, valueAt(r, i, days[5]), 1e-9)
+
+	_, err = NewStochRSIIndicator(2, 5, prices)
+	r.Error(err)
+}
+
+func TestROCIndicator(t *testing.T) {
+	r := require.New(t)
+
+	prices, days := dailyPrices(1, 2, 1, 2, 3, 2)
+
+	i, err := NewROCIndicator(2, prices)
+	r.NoError(err)
+
+	r.NotContains(i.ByTimestamp, days[1])
+	r.InDelta(0.0, valueAt(r, i, days[2]), 1e-9)
+	r.InDelta(200.0, valueAt(r, i, days[4]), 1e-9)
+	r.InDelta(0.0, valueAt(r, i, days[5]), 1e-9)
+
+	i, err = NewROCIndicator(1, prices)
+	r.NoError(err)
+	r.InDelta(-50.0, valueAt(r, i, days[2]), 1e-9)
+	r.InDelta(50.0, valueAt(r, i, days[4]), 1e-9)
+
+	// No rate of change from a zero price.
+	prices, days = dailyPrices(0, 1, 2)
+	i, err = NewROCIndicator(1, prices)
+	r.NoError(err)
+	r.NotContains(i.ByTimestamp, days[1])
+	r.InDelta(100.0, valueAt(r, i, days[2]), 1e-9)
+
+	_, err = NewROCIndicator(6, prices)
+	r.Error(err)
+}
+
+func TestWilliamsRIndicator(t *testing.T) {
+	r := require.New(t)
+
+	prices, days := dailyPrices(1, 2, 1, 2, 3, 2, 2, 2)
+
+	i, err := NewWilliamsRIndicator(3, prices)
+	r.NoError(err)
+
+	r.InDelta(-100.0, valueAt(r, i, days[2]), 1e-9)
+	r.InDelta(0.0, valueAt(r, i, days[3]), 1e-9)
+	r.InDelta(0.0, valueAt(r, i, days[4]), 1e-9)
+	r.InDelta(-100.0, valueAt(r, i, days[5]), 1e-9)
+	r.InDelta(-100.0, valueAt(r, i, days[6]), 1e-9)
+	r.InDelta(-50.0, valueAt(r, i, days[7]), 1e-9)
+
+	_, err = NewWilliamsRIndicator(0, prices)
+	r.Error(err)
+}
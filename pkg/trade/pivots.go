@@ -0,0 +1,251 @@
+package trade
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/anrid/traderbot/pkg/timeseries"
+	"github.com/pkg/errors"
+)
+
+type EventKind string
+
+const (
+	SwingHigh         EventKind = "swing_high"
+	SwingLow          EventKind = "swing_low"
+	Support           EventKind = "support"
+	Resistance        EventKind = "resistance"
+	BullishDivergence EventKind = "bullish_divergence"
+	BearishDivergence EventKind = "bearish_divergence"
+)
+
+// Event is a dated finding of the price analysis below, e.g. a swing high
+// or a divergence, that strategies can act on and charts can annotate.
+type Event struct {
+	Kind  EventKind `json:"kind"`
+	TS    int64     `json:"ts"`
+	Price float64   `json:"price"`
+	// ConfirmedTS is when the event becomes known, e.g. a swing high is
+	// only confirmed once N lower days have followed it. Strategies must
+	// not act on an event before this to avoid look-ahead bias.
+	ConfirmedTS int64 `json:"confirmed_ts"`
+	// FromTS and FromPrice are set for events spanning two points in time,
+	// i.e. the earlier swing of a divergence.
+	FromTS      int64   `json:"from_ts,omitempty"`
+	FromPrice   float64 `json:"from_price,omitempty"`
+	Description string  `json:"description"`
+}
+
+func (e Event) Date() string {
+	return timeseries.FromTSToDate(e.TS)
+}
+
+func (e Event) String() string {
+	return fmt.Sprintf("%s  %s", e.Date(), e.Description)
+}
+
+// FindSwings returns the swing highs and lows of a series in chronological
+// order. A swing high is higher than the strength days before and at least
+// as high as the strength days after it (and vice versa for swing lows),
+// so that only the first day of a flat top or bottom counts. The last
+// strength days can't be swings as they're not confirmed yet.
+func FindSwings(s timeseries.Series, strength int) ([]Event, error) {
+	if strength <= 0 {
+		return nil, errors.Errorf("invalid swing strength %d, must be positive", strength)
+	}
+
+	var events []Event
+	for i := strength; i < len(s)-strength; i++ {
+		v := s[i].V
+		high, low := true, true
+
+		for j := i - strength; j <= i+strength; j++ {
+			switch {
+			case j < i:
+				high = high && s[j].V < v
+				low = low && s[j].V > v
+			case j > i:
+				high = high && s[j].V <= v
+				low = low && s[j].V >= v
+			}
+		}
+
+		confirmed := s[i+strength].TS
+		if high {
+			events = append(events, Event{Kind: SwingHigh, TS: s[i].TS, Price: v, ConfirmedTS: confirmed,
+				Description: fmt.Sprintf("Swing high at %.4f", v)})
+		}
+		if low {
+			events = append(events, Event{Kind: SwingLow, TS: s[i].TS, Price: v, ConfirmedTS: confirmed,
+				Description: fmt.Sprintf("Swing low at %.4f", v)})
+		}
+	}
+
+	return events, nil
+}
+
+// Zone is a horizontal support or resistance price range formed by swings
+// at similar prices.
+type Zone struct {
+	Kind    EventKind `json:"kind"` // Support or Resistance
+	Low     float64   `json:"low"`
+	High    float64   `json:"high"`
+	Touches []Event   `json:"touches"` // The swings forming the zone, in chronological order
+}
+
+func (z Zone) Mid() float64 {
+	return (z.Low + z.High) / 2
+}
+
+// FindSupportResistanceZones groups swing highs and lows (see FindSwings)
+// into zones where each swing is within tolerance (e.g. 0.02 for 2%) of the
+// lowest swing in the zone, and returns the zones with at least minTouches
+// swings, lowest zone first. Zones below the last price are support, the
+// rest resistance.
+func FindSupportResistanceZones(s timeseries.Series, strength int, tolerance float64, minTouches int) ([]Zone, error) {
+	if tolerance < 0 {
+		return nil, errors.Errorf("invalid zone tolerance %f, must not be negative", tolerance)
+	}
+	if len(s) == 0 {
+		return nil, errors.New("no price data to find support and resistance zones in")
+	}
+
+	swings, err := FindSwings(s, strength)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(swings, func(i, j int) bool { return swings[i].Price < swings[j].Price })
+
+	var zones []Zone
+	var cur Zone
+	flush := func() {
+		if len(cur.Touches) > 0 && len(cur.Touches) >= minTouches {
+			sort.SliceStable(cur.Touches, func(i, j int) bool { return cur.Touches[i].TS < cur.Touches[j].TS })
+			zones = append(zones, cur)
+		}
+	}
+
+	for _, e := range swings {
+		if len(cur.Touches) == 0 || e.Price > cur.Low*(1+tolerance) {
+			flush()
+			cur = Zone{Low: e.Price}
+		}
+		cur.High = e.Price
+		cur.Touches = append(cur.Touches, e)
+	}
+	flush()
+
+	last := s[len(s)-1].V
+	for i := range zones {
+		zones[i].Kind = Resistance
+		if zones[i].High < last {
+			zones[i].Kind = Support
+		}
+	}
+
+	return zones, nil
+}
+
+// NewPivotPointsIndicator returns classic floor trader pivot points, each
+// day calculated from the previous day's candle. Use candles.Daily() or
+// weekly candles for daily or weekly pivots. Its outputs are:
+//
+// pivot = (high + low + close) / 3
+// r1    = 2 x pivot - low
+// s1    = 2 x pivot - high
+// r2    = pivot + (high - low)
+// s2    = pivot - (high - low)
+// r3    = high + 2 x (pivot - low)
+// s3    = low - 2 x (high - pivot)
+func NewPivotPointsIndicator(candles timeseries.Candles) (*Indicator, error) {
+	if len(candles) < 2 {
+		return nil, errors.Errorf("not enough price data (%d entries) for pivot points, need 2 entries", len(candles))
+	}
+
+	var pivots, r1s, s1s, r2s, s2s, r3s, s3s timeseries.Series
+	for i := 1; i < len(candles); i++ {
+		prev, ts := candles[i-1], candles[i].TS
+
+		p := (prev.High + prev.Low + prev.Close) / 3
+		r := prev.High - prev.Low
+
+		pivots = append(pivots, timeseries.ValueAt{TS: ts, V: p})
+		r1s = append(r1s, timeseries.ValueAt{TS: ts, V: 2*p - prev.Low})
+		s1s = append(s1s, timeseries.ValueAt{TS: ts, V: 2*p - prev.High})
+		r2s = append(r2s, timeseries.ValueAt{TS: ts, V: p + r})
+		s2s = append(s2s, timeseries.ValueAt{TS: ts, V: p - r})
+		r3s = append(r3s, timeseries.ValueAt{TS: ts, V: prev.High + 2*(p-prev.Low)})
+		s3s = append(s3s, timeseries.ValueAt{TS: ts, V: prev.Low - 2*(prev.High-p)})
+	}
+
+	return newMultiIndicator("Pivots",
+		Output{"pivot", pivots},
+		Output{"r1", r1s},
+		Output{"s1", s1s},
+		Output{"r2", r2s},
+		Output{"s2", s2s},
+		Output{"r3", r3s},
+		Output{"s3", s3s},
+	).withWarmUp(candles.Closes()), nil
+}
+
+// FindDivergences compares consecutive price swings (see FindSwings) with
+// the main output of an oscillator such as RSI on the same days:
+//
+// - Bullish: price makes a lower low while the oscillator makes a higher low.
+// - Bearish: price makes a higher high while the oscillator makes a lower high.
+//
+// Events are dated on the second swing, with the first in FromTS. Swings
+// more than maxDays apart are ignored, unless maxDays is 0. Swings on days
+// without an oscillator value, e.g. during its warm-up, are skipped.
+func FindDivergences(prices timeseries.Series, oscillator *Indicator, strength, maxDays int) ([]Event, error) {
+	swings, err := FindSwings(prices, strength)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	var prevHigh, prevLow *Event
+
+	for n := range swings {
+		e := &swings[n]
+		if _, found := oscillator.ByTimestamp[e.TS]; !found {
+			continue
+		}
+
+		prev := &prevLow
+		if e.Kind == SwingHigh {
+			prev = &prevHigh
+		}
+
+		if p := *prev; p != nil && (maxDays == 0 || timeseries.DiffDays(p.Date(), e.Date()) <= maxDays) {
+			from, to := oscillator.ForTimestamp(p.TS), oscillator.ForTimestamp(e.TS)
+
+			d := Event{TS: e.TS, Price: e.Price, ConfirmedTS: e.ConfirmedTS, FromTS: p.TS, FromPrice: p.Price}
+			switch {
+			case e.Kind == SwingLow && e.Price < p.Price && to > from:
+				d.Kind = BullishDivergence
+				d.Description = fmt.Sprintf("Bullish divergence: price lower low %.4f -> %.4f, %s higher low %.2f -> %.2f",
+					p.Price, e.Price, oscillator.Name, from, to)
+			case e.Kind == SwingHigh && e.Price > p.Price && to < from:
+				d.Kind = BearishDivergence
+				d.Description = fmt.Sprintf("Bearish divergence: price higher high %.4f -> %.4f, %s lower high %.2f -> %.2f",
+					p.Price, e.Price, oscillator.Name, from, to)
+			}
+			if d.Kind != "" {
+				events = append(events, d)
+			}
+		}
+
+		*prev = e
+	}
+
+	return events, nil
+}
+
+// SortEvents sorts events chronologically, e.g. after combining swings and
+// divergences.
+func SortEvents(events []Event) {
+	sort.SliceStable(events, func(i, j int) bool { return events[i].TS < events[j].TS })
+}
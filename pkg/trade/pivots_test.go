@@ -0,0 +1,146 @@
+package trade
+
+import (
+	"testing"
+
+	"github.com/anrid/traderbot/pkg/timeseries"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindSwings(t *testing.T) {
+	r := require.New(t)
+
+	prices, days := dailyPrices(1, 3, 2, 5, 4, 1, 2)
+
+	swings, err := FindSwings(prices, 1)
+	r.NoError(err)
+	r.Len(swings, 4)
+
+	var kinds []EventKind
+	var ts []int64
+	var ps []float64
+	for _, e := range swings {
+		kinds = append(kinds, e.Kind)
+		ts = append(ts, e.TS)
+		ps = append(ps, e.Price)
+	}
+	r.Equal([]EventKind{SwingHigh, SwingLow, SwingHigh, SwingLow}, kinds)
+	r.Equal([]int64{days[1], days[2], days[3], days[5]}, ts)
+	r.Equal([]float64{3, 2, 5, 1}, ps)
+
+	// Confirmed the day after with a strength of 1.
+	r.Equal(days[2], swings[0].ConfirmedTS)
+	r.Equal("2021-01-02  Swing high at 3.0000", swings[0].String())
+
+	// Only the first day of a flat top counts.
+	prices, days = dailyPrices(1, 3, 3, 1)
+	swings, err = FindSwings(prices, 1)
+	r.NoError(err)
+	r.Len(swings, 1)
+	r.Equal(days[1], swings[0].TS)
+
+	// Not enough days on either side.
+	swings, err = FindSwings(prices, 2)
+	r.NoError(err)
+	r.Empty(swings)
+
+	_, err = FindSwings(prices, 0)
+	r.Error(err)
+}
+
+func TestFindSupportResistanceZones(t *testing.T) {
+	r := require.New(t)
+
+	// Swing lows at 10.1 and 9.9, swing highs at 12 and 12.1.
+	prices, days := dailyPrices(10, 12, 10.1, 12.1, 9.9, 11)
+
+	zones, err := FindSupportResistanceZones(prices, 1, 0.03, 2)
+	r.NoError(err)
+	r.Len(zones, 2)
+
+	r.Equal(Support, zones[0].Kind)
+	r.Equal(9.9, zones[0].Low)
+	r.Equal(10.1, zones[0].High)
+	r.InDelta(10.0, zones[0].Mid(), 1e-9)
+	r.Equal(days[2], zones[0].Touches[0].TS)
+	r.Equal(days[4], zones[0].Touches[1].TS)
+
+	r.Equal(Resistance, zones[1].Kind)
+	r.Equal(12.0, zones[1].Low)
+	r.Equal(12.1, zones[1].High)
+
+	zones, err = FindSupportResistanceZones(prices, 1, 0, 1)
+	r.NoError(err)
+	r.Len(zones, 4)
+
+	zones, err = FindSupportResistanceZones(prices, 1, 0.03, 3)
+	r.NoError(err)
+	r.Empty(zones)
+
+	_, err = FindSupportResistanceZones(prices, 1, -0.01, 2)
+	r.Error(err)
+}
+
+func TestPivotPointsIndicator(t *testing.T) {
+	r := require.New(t)
+
+	_, days := dailyPrices(0, 0)
+	cs := timeseries.Candles{
+		{TS: days[0], High: 12, Low: 8, Close: 10},
+		{TS: days[1], High: 11, Low: 9, Close: 9},
+	}
+
+	pp, err := NewPivotPointsIndicator(cs)
+	r.NoError(err)
+	r.Equal(1, pp.WarmUp)
+	r.Equal([]int64{days[1]}, timestamps(pp.Series()))
+
+	for name, want := range map[string]float64{
+		"pivot": 10, "r1": 12, "s1": 8, "r2": 14, "s2": 6, "r3": 16, "s3": 4,
+	} {
+		r.InDelta(want, output(r, pp, name).ForTimestamp(days[1]), 1e-9, name)
+	}
+
+	_, err = NewPivotPointsIndicator(cs[:1])
+	r.Error(err)
+}
+
+func TestFindDivergences(t *testing.T) {
+	r := require.New(t)
+
+	// Price makes a lower low (8 -> 7) while the oscillator makes a higher
+	// low (20 -> 30).
+	prices, days := dailyPrices(10, 8, 9, 7, 8)
+	osc, _ := dailyPrices(50, 20, 40, 30, 45)
+
+	events, err := FindDivergences(prices, newIndicator("RSI", osc), 1, 0)
+	r.NoError(err)
+	r.Len(events, 1)
+	r.Equal(BullishDivergence, events[0].Kind)
+	r.Equal(days[3], events[0].TS)
+	r.Equal(days[1], events[0].FromTS)
+	r.Equal(8.0, events[0].FromPrice)
+	r.Equal(7.0, events[0].Price)
+	r.Equal(days[4], events[0].ConfirmedTS)
+
+	// Price makes a higher high (5 -> 6) while the oscillator makes a lower
+	// high (70 -> 60).
+	prices, days = dailyPrices(1, 5, 3, 6, 2)
+	osc, _ = dailyPrices(50, 70, 50, 60, 40)
+
+	events, err = FindDivergences(prices, newIndicator("RSI", osc), 1, 0)
+	r.NoError(err)
+	r.Len(events, 1)
+	r.Equal(BearishDivergence, events[0].Kind)
+	r.Equal(days[3], events[0].TS)
+
+	// Swings 2 days apart.
+	events, err = FindDivergences(prices, newIndicator("RSI", osc), 1, 1)
+	r.NoError(err)
+	r.Empty(events)
+
+	// No oscillator value on the first swing.
+	events, err = FindDivergences(prices, newIndicator("RSI", osc[2:]), 1, 0)
+	r.NoError(err)
+	r.Empty(events)
+}
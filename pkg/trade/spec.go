@@ -0,0 +1,468 @@
+package trade
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/anrid/traderbot/pkg/coingecko"
+	"github.com/pkg/errors"
+)
+
+// IndicatorFactory creates an indicator for a market from the arguments
+// of a spec, see Indicators.
+type IndicatorFactory struct {
+	Description string
+	Args        []SpecArg
+	Outputs     []string // Output names, main output first; empty for single-output indicators
+	New         func(args []float64, m *coingecko.Market) (*Indicator, error)
+}
+
+type SpecArg struct {
+	Name    string
+	Integer bool
+}
+
+var daysArg = SpecArg{Name: "days", Integer: true}
+
+// Indicators are the indicators that can be referenced by spec strings,
+// see ParseSpec. Add to it to make custom indicators available to all
+// commands. Indicators based on candles use Market.Candles, which are only
+// close-to-close unless the market has OHLC candles.
+var Indicators = map[string]*IndicatorFactory{
+	"ema": {
+		Description: "Exponential Moving Average",
+		Args:        []SpecArg{daysArg},
+		New: func(a []float64, m *coingecko.Market) (*Indicator, error) {
+			return newEMAIndicator(int(a[0]), m.Prices)
+		},
+	},
+	"sma": {
+		Description: "Simple Moving Average",
+		Args:        []SpecArg{daysArg},
+		New: func(a []float64, m *coingecko.Market) (*Indicator, error) {
+			return NewSMAIndicator(int(a[0]), m.Prices)
+		},
+	},
+	"wma": {
+		Description: "Weighted Moving Average",
+		Args:        []SpecArg{daysArg},
+		New: func(a []float64, m *coingecko.Market) (*Indicator, error) {
+			return NewWMAIndicator(int(a[0]), m.Prices)
+		},
+	},
+	"hma": {
+		Description: "Hull Moving Average",
+		Args:        []SpecArg{daysArg},
+		New: func(a []float64, m *coingecko.Market) (*Indicator, error) {
+			return NewHMAIndicator(int(a[0]), m.Prices)
+		},
+	},
+	"dema": {
+		Description: "Double Exponential Moving Average",
+		Args:        []SpecArg{daysArg},
+		New: func(a []float64, m *coingecko.Market) (*Indicator, error) {
+			return NewDEMAIndicator(int(a[0]), m.Prices)
+		},
+	},
+	"tema": {
+		Description: "Triple Exponential Moving Average",
+		Args:        []SpecArg{daysArg},
+		New: func(a []float64, m *coingecko.Market) (*Indicator, error) {
+			return NewTEMAIndicator(int(a[0]), m.Prices)
+		},
+	},
+	"vwma": {
+		Description: "Volume Weighted Moving Average",
+		Args:        []SpecArg{daysArg},
+		New: func(a []float64, m *coingecko.Market) (*Indicator, error) {
+			return NewVWMAIndicator(int(a[0]), m.Prices, m.TotalVolumes)
+		},
+	},
+	"rsi": {
+		Description: "Relative Strength Index",
+		Args:        []SpecArg{daysArg},
+		New: func(a []float64, m *coingecko.Market) (*Indicator, error) {
+			return NewRSIIndicator(int(a[0]), m.Prices)
+		},
+	},
+	"stochrsi": {
+		Description: "Stochastic RSI",
+		Args:        []SpecArg{{Name: "rsi_days", Integer: true}, {Name: "stoch_days", Integer: true}},
+		New: func(a []float64, m *coingecko.Market) (*Indicator, error) {
+			return NewStochRSIIndicator(int(a[0]), int(a[1]), m.Prices)
+		},
+	},
+	"roc": {
+		Description: "Rate of Change",
+		Args:        []SpecArg{daysArg},
+		New: func(a []float64, m *coingecko.Market) (*Indicator, error) {
+			return NewROCIndicator(int(a[0]), m.Prices)
+		},
+	},
+	"willr": {
+		Description: "Williams %R",
+		Args:        []SpecArg{daysArg},
+		New: func(a []float64, m *coingecko.Market) (*Indicator, error) {
+			return NewWilliamsRIndicator(int(a[0]), m.Prices)
+		},
+	},
+	"macd": {
+		Description: "Moving Average Convergence Divergence",
+		Args:        []SpecArg{{Name: "fast", Integer: true}, {Name: "slow", Integer: true}, {Name: "signal", Integer: true}},
+		Outputs:     []string{"line", "signal", "hist"},
+		New: func(a []float64, m *coingecko.Market) (*Indicator, error) {
+			return NewMACDIndicator(int(a[0]), int(a[1]), int(a[2]), m.Prices)
+		},
+	},
+	"bb": {
+		Description: "Bollinger Bands",
+		Args:        []SpecArg{daysArg, {Name: "k"}},
+		Outputs:     []string{"middle", "upper", "lower", "percent_b", "bandwidth"},
+		New: func(a []float64, m *coingecko.Market) (*Indicator, error) {
+			return NewBollingerIndicator(int(a[0]), a[1], m.Prices)
+		},
+	},
+	"atr": {
+		Description: "Average True Range",
+		Args:        []SpecArg{daysArg},
+		New: func(a []float64, m *coingecko.Market) (*Indicator, error) {
+			return NewATRIndicator(int(a[0]), m.Candles())
+		},
+	},
+	"kc": {
+		Description: "Keltner Channels",
+		Args:        []SpecArg{{Name: "ema_days", Integer: true}, {Name: "atr_days", Integer: true}, {Name: "multiplier"}},
+		Outputs:     []string{"middle", "upper", "lower"},
+		New: func(a []float64, m *coingecko.Market) (*Indicator, error) {
+			return NewKeltnerIndicator(int(a[0]), int(a[1]), a[2], m.Candles())
+		},
+	},
+	"supertrend": {
+		Description: "SuperTrend",
+		Args:        []SpecArg{daysArg, {Name: "multiplier"}},
+		Outputs:     []string{ValueOutput, "direction"},
+		New: func(a []float64, m *coingecko.Market) (*Indicator, error) {
+			return NewSuperTrendIndicator(int(a[0]), a[1], m.Candles())
+		},
+	},
+	"psar": {
+		Description: "Parabolic SAR",
+		Args:        []SpecArg{{Name: "step"}, {Name: "max"}},
+		Outputs:     []string{ValueOutput, "direction"},
+		New: func(a []float64, m *coingecko.Market) (*Indicator, error) {
+			return NewParabolicSARIndicator(a[0], a[1], m.Candles())
+		},
+	},
+	"adx": {
+		Description: "Average Directional Index and DMI",
+		Args:        []SpecArg{daysArg},
+		Outputs:     []string{"adx", "plus_di", "minus_di"},
+		New: func(a []float64, m *coingecko.Market) (*Indicator, error) {
+			return NewADXIndicator(int(a[0]), m.Candles())
+		},
+	},
+	"ichimoku": {
+		Description: "Ichimoku Cloud",
+		Args:        []SpecArg{{Name: "tenkan", Integer: true}, {Name: "kijun", Integer: true}, {Name: "senkou_b", Integer: true}},
+		Outputs:     []string{"tenkan", "kijun", "senkou_a", "senkou_b", "chikou"},
+		New: func(a []float64, m *coingecko.Market) (*Indicator, error) {
+			return NewIchimokuIndicator(int(a[0]), int(a[1]), int(a[2]), m.Candles())
+		},
+	},
+	"pivots": {
+		Description: "Classic pivot points from the previous day",
+		Outputs:     []string{"pivot", "r1", "s1", "r2", "s2", "r3", "s3"},
+		New: func(a []float64, m *coingecko.Market) (*Indicator, error) {
+			return NewPivotPointsIndicator(m.Candles())
+		},
+	},
+	"obv": {
+		Description: "On-Balance Volume",
+		New: func(a []float64, m *coingecko.Market) (*Indicator, error) {
+			return NewOBVIndicator(m.Prices, m.TotalVolumes)
+		},
+	},
+	"vma": {
+		Description: "Simple Moving Average of volume",
+		Args:        []SpecArg{daysArg},
+		New: func(a []float64, m *coingecko.Market) (*Indicator, error) {
+			return NewVolumeMAIndicator(int(a[0]), m.TotalVolumes)
+		},
+	},
+	"vspike": {
+		Description: "Volume relative to the average of the days before",
+		Args:        []SpecArg{daysArg},
+		New: func(a []float64, m *coingecko.Market) (*Indicator, error) {
+			return NewVolumeSpikeIndicator(int(a[0]), m.TotalVolumes)
+		},
+	},
+	"mfi": {
+		Description: "Money Flow Index",
+		Args:        []SpecArg{daysArg},
+		New: func(a []float64, m *coingecko.Market) (*Indicator, error) {
+			return NewMFIIndicator(int(a[0]), m.Candles(), m.TotalVolumes)
+		},
+	},
+	"mcap_roc": {
+		Description: "Market cap momentum",
+		Args:        []SpecArg{daysArg},
+		New: func(a []float64, m *coingecko.Market) (*Indicator, error) {
+			return NewMarketCapMomentumIndicator(int(a[0]), m.MarketCaps)
+		},
+	},
+}
+
+// Spec references an indicator by name, arguments and optionally an
+// output, e.g. "ema(9)", "rsi(14)", "bb(20,2).upper" or
+// "macd(12,26,9).hist".
+type Spec struct {
+	Expr    string
+	Name    string
+	Args    []float64
+	Output  string // Empty for the main output
+	factory *IndicatorFactory
+}
+
+// ParseSpec parses and validates an indicator spec against Indicators.
+func ParseSpec(expr string) (*Spec, error) {
+	ts, err := tokenizeSpec(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	end := specToken{pos: len([]rune(expr)) + 1}
+	next := func(i int) specToken {
+		if i < len(ts) {
+			return ts[i]
+		}
+		return end
+	}
+
+	nameTok := next(0)
+	if nameTok.text == "" || !isSpecIdent([]rune(nameTok.text)[0]) {
+		return nil, errors.Errorf("expected indicator name at position %d", nameTok.pos)
+	}
+
+	s := &Spec{Expr: expr, Name: strings.ToLower(nameTok.text)}
+
+	f, found := Indicators[s.Name]
+	if !found {
+		return nil, errors.Errorf("unknown indicator `%s` at position %d, expected one of: %s", nameTok.text, nameTok.pos, strings.Join(IndicatorNames(), ", "))
+	}
+	s.factory = f
+
+	i := 1
+	var argToks []specToken
+	if next(i).text == "(" {
+		i++
+		for next(i).text != ")" {
+			if len(argToks) > 0 {
+				if next(i).text != "," {
+					return nil, errors.Errorf("expected `,` or `)` at position %d", next(i).pos)
+				}
+				i++
+			}
+
+			t := next(i)
+			if t == end {
+				return nil, errors.Errorf("expected `)` at position %d", t.pos)
+			}
+			argToks = append(argToks, t)
+			i++
+		}
+		i++
+	}
+
+	if len(argToks) != len(f.Args) {
+		var names []string
+		for _, a := range f.Args {
+			names = append(names, a.Name)
+		}
+		pos := nameTok.pos
+		if len(argToks) > len(f.Args) {
+			pos = argToks[len(f.Args)].pos
+		}
+		return nil, errors.Errorf("%s expects %d argument(s) (%s), got %d at position %d", s.Name, len(f.Args), strings.Join(names, ", "), len(argToks), pos)
+	}
+
+	for n, t := range argToks {
+		arg := f.Args[n]
+
+		v, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, errors.Errorf("invalid number `%s` for %s at position %d", t.text, arg.Name, t.pos)
+		}
+		if v <= 0 || (arg.Integer && v != math.Trunc(v)) {
+			kind := "positive number"
+			if arg.Integer {
+				kind = "positive integer"
+			}
+			return nil, errors.Errorf("invalid %s `%s` at position %d, expected a %s", arg.Name, t.text, t.pos, kind)
+		}
+		s.Args = append(s.Args, v)
+	}
+
+	if next(i).text == "." {
+		i++
+		t := next(i)
+		if t == end {
+			return nil, errors.Errorf("expected output name at position %d", t.pos)
+		}
+
+		outputs := f.Outputs
+		if len(outputs) == 0 {
+			outputs = []string{ValueOutput}
+		}
+
+		for _, o := range outputs {
+			if strings.EqualFold(o, t.text) {
+				s.Output = o
+			}
+		}
+		if s.Output == "" {
+			return nil, errors.Errorf("unknown output `%s` at position %d, %s has: %s", t.text, t.pos, s.Name, strings.Join(outputs, ", "))
+		}
+		i++
+	}
+
+	if i < len(ts) {
+		return nil, errors.Errorf("unexpected `%s` at position %d", ts[i].text, ts[i].pos)
+	}
+
+	return s, nil
+}
+
+func isSpecIdent(r rune) bool {
+	return unicode.IsLetter(r) || r == '_'
+}
+
+type specToken struct {
+	text string
+	pos  int // Position in the spec, starting at 1
+}
+
+func tokenizeSpec(expr string) (ts []specToken, err error) {
+	rs := []rune(expr)
+
+	isNumber := func(r rune) bool {
+		return unicode.IsDigit(r) || strings.ContainsRune(".eE+-", r)
+	}
+
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		j := i + 1
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case strings.ContainsRune("(),", r), r == '.' && (j == len(rs) || !unicode.IsDigit(rs[j])):
+			// Punctuation, including the `.` before an output name.
+		case unicode.IsDigit(r) || strings.ContainsRune(".+-", r):
+			for j < len(rs) && isNumber(rs[j]) {
+				j++
+			}
+		case isSpecIdent(r):
+			for j < len(rs) && (isSpecIdent(rs[j]) || unicode.IsDigit(rs[j])) {
+				j++
+			}
+		default:
+			return nil, errors.Errorf("unexpected character `%c` at position %d", r, i+1)
+		}
+		ts = append(ts, specToken{string(rs[i:j]), i + 1})
+		i = j
+	}
+	return
+}
+
+// New creates the indicator for the given market.
+func (s *Spec) New(m *coingecko.Market) (*Indicator, error) {
+	f := s.factory
+	if f == nil {
+		f = Indicators[s.Name]
+	}
+	if f == nil {
+		return nil, errors.Errorf("unknown indicator `%s`", s.Name)
+	}
+
+	in, err := f.New(s.Args, m)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not create indicator %s for %s", s, m.ID)
+	}
+	if s.Output == "" {
+		return in, nil
+	}
+	return in.Output(s.Output)
+}
+
+func (s *Spec) String() string {
+	var args []string
+	for _, a := range s.Args {
+		args = append(args, strconv.FormatFloat(a, 'f', -1, 64))
+	}
+
+	str := s.Name
+	if len(args) > 0 {
+		str += "(" + strings.Join(args, ",") + ")"
+	}
+	if s.Output != "" {
+		str += "." + s.Output
+	}
+	return str
+}
+
+// NewIndicatorFromSpec parses a spec and creates the indicator for the
+// given market.
+func NewIndicatorFromSpec(spec string, m *coingecko.Market) (*Indicator, error) {
+	s, err := ParseSpec(spec)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid indicator spec `%s`", spec)
+	}
+	return s.New(m)
+}
+
+// IndicatorNames returns the names of all registered indicators.
+func IndicatorNames() (names []string) {
+	for name := range Indicators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// IndicatorUsage describes all registered indicators, one per line, e.g.
+// for command line help.
+func IndicatorUsage() string {
+	var specs []string
+	var width int
+	for _, name := range IndicatorNames() {
+		f := Indicators[name]
+
+		var args []string
+		for _, a := range f.Args {
+			args = append(args, a.Name)
+		}
+
+		spec := name
+		if len(args) > 0 {
+			spec += "(" + strings.Join(args, ",") + ")"
+		}
+		if len(f.Outputs) > 0 {
+			spec += ".{" + strings.Join(f.Outputs, ",") + "}"
+		}
+
+		specs = append(specs, spec)
+		if len(spec) > width {
+			width = len(spec)
+		}
+	}
+
+	var lines []string
+	for n, name := range IndicatorNames() {
+		lines = append(lines, fmt.Sprintf("  %-*s  %s", width, specs[n], Indicators[name].Description))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,116 @@
+package trade
+
+import (
+	"testing"
+
+	"github.com/anrid/traderbot/pkg/coingecko"
+	"github.com/anrid/traderbot/pkg/timeseries"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSpec(t *testing.T) {
+	r := require.New(t)
+
+	s, err := ParseSpec("ema(9)")
+	r.NoError(err)
+	r.Equal("ema", s.Name)
+	r.Equal([]float64{9}, s.Args)
+	r.Equal("", s.Output)
+	r.Equal("ema(9)", s.String())
+
+	s, err = ParseSpec(" BB( 20, 2.5 ).Upper ")
+	r.NoError(err)
+	r.Equal("bb", s.Name)
+	r.Equal([]float64{20, 2.5}, s.Args)
+	r.Equal("upper", s.Output)
+	r.Equal("bb(20,2.5).upper", s.String())
+
+	s, err = ParseSpec("macd(12,26,9).hist")
+	r.NoError(err)
+	r.Equal("macd(12,26,9).hist", s.String())
+
+	s, err = ParseSpec("obv")
+	r.NoError(err)
+	r.Empty(s.Args)
+
+	s, err = ParseSpec("rsi(14).value")
+	r.NoError(err)
+	r.Equal(ValueOutput, s.Output)
+
+	for spec, expected := range map[string]string{
+		"":                    "expected indicator name at position 1",
+		"9":                   "expected indicator name at position 1",
+		"emma(9)":             "unknown indicator `emma` at position 1, expected one of: ",
+		"ema":                 "ema expects 1 argument(s) (days), got 0 at position 1",
+		"ema(":                "expected `)` at position 5",
+		"ema(9":               "expected `,` or `)` at position 6",
+		"ema(9 21)":           "expected `,` or `)` at position 7",
+		"ema(9,21)":           "ema expects 1 argument(s) (days), got 2 at position 7",
+		"ema(x)":              "invalid number `x` for days at position 5",
+		"ema(9.5)":            "invalid days `9.5` at position 5, expected a positive integer",
+		"bb(20,-2)":           "invalid k `-2` at position 7, expected a positive number",
+		"macd(12,26,9).histo": "unknown output `histo` at position 15, macd has: line, signal, hist",
+		"macd(12,26,9).":      "expected output name at position 15",
+		"rsi(14) x":           "unexpected `x` at position 9",
+		"rsi[14]":             "unexpected character `[` at position 4",
+	} {
+		_, err := ParseSpec(spec)
+		r.Error(err, spec)
+		r.Contains(err.Error(), expected, spec)
+	}
+}
+
+func TestNewIndicatorFromSpec(t *testing.T) {
+	r := require.New(t)
+
+	prices, days := dailyPrices(1, 2, 3, 4, 3, 2, 1, 2, 3, 4)
+	volumes, _ := dailyPrices(1, 1, 1, 1, 1, 1, 1, 1, 1, 1)
+	m := &coingecko.Market{ID: "test", Prices: prices, TotalVolumes: volumes, MarketCaps: prices}
+
+	ema, err := NewIndicatorFromSpec("ema(3)", m)
+	r.NoError(err)
+	r.Equal(NewEMAIndicator(3, prices).Outputs, ema.Outputs)
+
+	hist, err := NewIndicatorFromSpec("macd(2,3,2).hist", m)
+	r.NoError(err)
+	r.Equal("MACD(2,3,2).hist", hist.Name)
+	r.InDelta(-0.148148, hist.ForTimestamp(days[5]), 1e-6)
+
+	// Every registered indicator can be created with small arguments.
+	for _, name := range IndicatorNames() {
+		f := Indicators[name]
+		s := &Spec{Name: name}
+		for range f.Args {
+			s.Args = append(s.Args, 2)
+		}
+		if name == "macd" || name == "kc" {
+			s.Args = []float64{2, 3, 2}
+		}
+
+		in, err := s.New(m)
+		r.NoError(err, name)
+		r.NotEmpty(in.Series(), name)
+		for _, o := range f.Outputs {
+			r.Contains(in.OutputNames(), o, name)
+		}
+	}
+
+	// Candle-based indicators use the market's OHLC candles if it has them.
+	closeToClose, err := NewIndicatorFromSpec("atr(2)", m)
+	r.NoError(err)
+
+	for _, p := range prices {
+		m.OHLC = append(m.OHLC, timeseries.Candle{TS: p.TS, Open: p.V, High: p.V + 5, Low: p.V - 5, Close: p.V})
+	}
+	atr, err := NewIndicatorFromSpec("atr(2)", m)
+	r.NoError(err)
+	r.InDelta(10.0, valueAt(r, atr, days[5]), 1e-9)
+	r.Less(valueAt(r, closeToClose, days[5]), 10.0)
+	m.OHLC = nil
+
+	_, err = NewIndicatorFromSpec("ema(20)", m)
+	r.EqualError(err, "could not create indicator ema(20) for test: not enough price data (10 entries) for observation period (20 days)")
+
+	_, err = NewIndicatorFromSpec("ema(", m)
+	r.EqualError(err, "invalid indicator spec `ema(`: expected `)` at position 5")
+}
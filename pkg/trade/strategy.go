@@ -1,85 +1,165 @@
 package trade
 
 import (
-	"github.com/anrid/traderbot/pkg/coingecko"
+	"fmt"
+
+	"github.com/anrid/traderbot/pkg/timeseries"
 	"github.com/pkg/errors"
 )
 
+// Strategy decides what to trade, one bar at a time, see RunStrategy.
+// Strategies only keep state between the bars of a run: every run starts
+// with a bar with Index 0, on which a strategy must forget any earlier
+// run, so that the same strategy can be run again, e.g. on another market.
+type Strategy interface {
+	Name() string
+	// Next receives each bar of the tracked market in chronological order
+	// and returns the orders to place on the traded market at that bar.
+	Next(bar Bar) ([]Order, error)
+}
+
+// Bar is a single day of the tracked market.
+type Bar struct {
+	Index int // Index into the tracked market's prices
+	TS    int64
+	Price float64
+}
+
+func (b Bar) Date() string {
+	return timeseries.FromTSToDate(b.TS)
+}
+
+// Order asks to buy or sell a percentage (0.0 - 100.0] of the available
+// fiat or units at the bar it's placed.
+type Order struct {
+	Side  Side
+	Size  float64
+	Price float64 // Fill price, e.g. a stop price, or 0 for the day's price
+}
+
 type EMACrossOverStrategy struct {
 	ShortEMA *Indicator
 	LongEMA  *Indicator
-	Track    *coingecko.Market
-	Trade    *coingecko.Market
-	Trades   []*Trade
+
+	lastShort float64
+	lastLong  float64
+	havePrev  bool // Whether lastShort and lastLong are set
 }
 
-func NewEMACrossOverStrategy(shortEMA, longEMA *Indicator, track, trade *coingecko.Market) (*EMACrossOverStrategy, error) {
-	strat := &EMACrossOverStrategy{
+func NewEMACrossOverStrategy(shortEMA, longEMA *Indicator) *EMACrossOverStrategy {
+	return &EMACrossOverStrategy{
 		ShortEMA: shortEMA,
 		LongEMA:  longEMA,
-		Track:    track,
-		Trade:    trade,
 	}
+}
 
-	// pr := message.NewPrinter(language.English)
-	var lastShort float64
-	var lastLong float64
+func (s *EMACrossOverStrategy) Name() string {
+	return fmt.Sprintf("%s/%s CrossOver Strategy", s.ShortEMA.Name, s.LongEMA.Name)
+}
 
-	for _, p := range track.Prices {
-		short := shortEMA.ForTimestamp(p.TS)
-		long := longEMA.ForTimestamp(p.TS)
-		if short == 0.0 || long == 0.0 {
-			// Could not find EMA values for both short and long observation periods.
-			continue
-		}
+func (s *EMACrossOverStrategy) Next(bar Bar) (orders []Order, err error) {
+	if bar.Index == 0 {
+		s.havePrev = false
+	}
+
+	// Any indicator may be crossed over, and some (e.g. MACD) are zero or
+	// negative, so only missing values are skipped.
+	short, foundShort := s.ShortEMA.ByTimestamp[bar.TS]
+	long, foundLong := s.LongEMA.ByTimestamp[bar.TS]
+	if !foundShort || !foundLong {
+		// Could not find EMA values for both short and long observation periods.
+		return
+	}
+
+	if s.havePrev {
+		shortCrossedOverLongFromAbove := s.lastShort > s.lastLong && short < long // Sell signal.
+		shortCrossedOverLongFromBelow := s.lastShort < s.lastLong && short > long // Buy signal.
 
-		// fmt.Printf("[%s] short %s = %.04f  --  long %s = %.04f\n", p.Date(), shortEMA.Name, short, longEMA.Name, long)
-
-		if lastShort > 0.0 && lastLong > 0.0 {
-			shortCrossedOverLongFromAbove := lastShort > lastLong && short < long // Sell signal.
-			shortCrossedOverLongFromBelow := lastShort < lastLong && short > long // Buy signal.
-
-			if shortCrossedOverLongFromAbove {
-				// Sell signal.
-				// pr.Printf("[%s] sell @ %.04f\n", p.Date(), p.V)
-
-				t, err := NewSellAtDate(p.Date(), 100.0, trade)
-				if err != nil {
-					return nil, errors.Wrapf(err, "could not create sell trade for %s", trade.ID)
-				}
-				strat.Trades = append(strat.Trades, t)
-			} else if shortCrossedOverLongFromBelow {
-				// Buy signal.
-				// pr.Printf("[%s] buy @ %.04f\n", p.Date(), p.V)
-
-				t, err := NewBuyAtDate(p.Date(), 100.0, trade)
-				if err != nil {
-					return nil, errors.Wrapf(err, "could not create buy trade for %s", trade.ID)
-				}
-				strat.Trades = append(strat.Trades, t)
-			}
+		if shortCrossedOverLongFromAbove {
+			orders = append(orders, Order{Side: Sell, Size: 100.0})
+		} else if shortCrossedOverLongFromBelow {
+			orders = append(orders, Order{Side: Buy, Size: 100.0})
 		}
+	}
+
+	s.lastShort = short
+	s.lastLong = long
+	s.havePrev = true
+	return
+}
 
-		lastShort = short
-		lastLong = long
+// MACDTrigger selects which MACD crossings a MACDStrategy trades on.
+type MACDTrigger int
+
+const (
+	// MACDSignalCross trades the MACD line crossing its signal line,
+	// i.e. the histogram crossing zero: buy when crossing from below,
+	// sell when crossing from above.
+	MACDSignalCross MACDTrigger = iota + 1
+	// MACDZeroCross trades the MACD line crossing zero, i.e. the fast EMA
+	// crossing the slow EMA.
+	MACDZeroCross
+)
+
+type MACDStrategy struct {
+	MACD    *Indicator // See NewMACDIndicator
+	Trigger MACDTrigger
+
+	values map[int64]float64
+	last   float64 // Last non-zero value
+}
+
+func NewMACDStrategy(macd *Indicator, trigger MACDTrigger) (*MACDStrategy, error) {
+	var output string
+	switch trigger {
+	case MACDSignalCross:
+		output = "hist"
+	case MACDZeroCross:
+		output = "line"
+	default:
+		return nil, errors.Errorf("invalid MACD trigger %d", trigger)
 	}
 
-	// Feature flag: Add one last sell trade if the last trade is a buy.
-	addForcedSell := false
-	if addForcedSell {
-		if len(strat.Trades) > 0 {
-			last := strat.Trades[len(strat.Trades)-1]
-			if last.Side == Buy {
-				latestPrice := trade.Prices[len(trade.Prices)-1]
-
-				forcedSell, err := NewSellAtDate(latestPrice.Date(), 100.0, trade)
-				if err != nil {
-					return nil, errors.Wrapf(err, "could not create one last forced sale for %s", trade.ID)
-				}
-				strat.Trades = append(strat.Trades, forcedSell)
-			}
-		}
+	in, err := macd.Output(output)
+	if err != nil {
+		return nil, err
 	}
 
-	return strat, nil
+	return &MACDStrategy{
+		MACD:    macd,
+		Trigger: trigger,
+		values:  in.ByTimestamp,
+	}, nil
+}
+
+func (s *MACDStrategy) Name() string {
+	if s.Trigger == MACDZeroCross {
+		return fmt.Sprintf("%s Zero Cross Strategy", s.MACD.Name)
+	}
+	return fmt.Sprintf("%s Signal Cross Strategy", s.MACD.Name)
+}
+
+func (s *MACDStrategy) Next(bar Bar) (orders []Order, err error) {
+	if bar.Index == 0 {
+		s.last = 0
+	}
+
+	cur, found := s.values[bar.TS]
+	if !found {
+		// MACD not available yet.
+		return
+	}
+
+	if s.last > 0.0 && cur < 0.0 {
+		orders = append(orders, Order{Side: Sell, Size: 100.0})
+	} else if s.last < 0.0 && cur > 0.0 {
+		orders = append(orders, Order{Side: Buy, Size: 100.0})
+	}
+
+	// A crossing may touch zero on the way, so compare with the last
+	// value on either side of it.
+	if cur != 0.0 {
+		s.last = cur
+	}
+	return
 }
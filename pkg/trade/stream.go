@@ -0,0 +1,268 @@
+package trade
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/anrid/traderbot/pkg/timeseries"
+	"github.com/pkg/errors"
+)
+
+// stepper calculates an indicator one input value at a time. Its exported
+// fields are the state saved by Indicator.Snapshot.
+type stepper interface {
+	// step returns a value for every output, or ready = false during the
+	// warm-up period.
+	step(v float64) (outputs []float64, ready bool)
+}
+
+// NewEMAStream returns an EMA that's calculated incrementally using
+// Indicator.Update, giving the same values as NewEMAIndicator.
+func NewEMAStream(days int) (*Indicator, error) {
+	if days <= 0 {
+		return nil, errors.Errorf("invalid observation period (%d days)", days)
+	}
+	return newStream(fmt.Sprintf("%d-Day EMA", days), &emaStepper{Days: days}, ValueOutput), nil
+}
+
+// NewSMAStream returns an SMA that's calculated incrementally, see
+// NewEMAStream.
+func NewSMAStream(days int) (*Indicator, error) {
+	if days <= 0 {
+		return nil, errors.Errorf("invalid observation period (%d days)", days)
+	}
+	return newStream(fmt.Sprintf("%d-Day SMA", days), &smaStepper{Days: days}, ValueOutput), nil
+}
+
+// NewRSIStream returns an RSI that's calculated incrementally, see
+// NewEMAStream.
+func NewRSIStream(days int) (*Indicator, error) {
+	if days <= 0 {
+		return nil, errors.Errorf("invalid observation period (%d days)", days)
+	}
+	return newStream(fmt.Sprintf("%d-Day RSI", days), &rsiStepper{Days: days}, ValueOutput), nil
+}
+
+// NewMACDStream returns a MACD that's calculated incrementally, see
+// NewEMAStream.
+func NewMACDStream(fast, slow, signal int) (*Indicator, error) {
+	if fast <= 0 || slow <= fast || signal <= 0 {
+		return nil, errors.Errorf("invalid MACD periods (%d, %d, %d), fast must be shorter than slow", fast, slow, signal)
+	}
+	s := &macdStepper{
+		Fast:   emaStepper{Days: fast},
+		Slow:   emaStepper{Days: slow},
+		Signal: emaStepper{Days: signal},
+	}
+	return newStream(fmt.Sprintf("MACD(%d,%d,%d)", fast, slow, signal), s, "line", "signal", "hist"), nil
+}
+
+func newStream(name string, s stepper, outputs ...string) *Indicator {
+	outs := make([]Output, len(outputs))
+	for n, o := range outputs {
+		outs[n].Name = o
+	}
+
+	i := newMultiIndicator(name, outs...)
+	i.stream = s
+	return i
+}
+
+// Update adds the next input value, e.g. a new daily close, to an
+// indicator created by one of the New*Stream functions. Values must be
+// added in ascending order.
+func (i *Indicator) Update(v timeseries.ValueAt) error {
+	if i.stream == nil {
+		return errors.Errorf("indicator %s does not support updates", i.Name)
+	}
+	if i.updated && v.TS <= i.lastTS {
+		return errors.Errorf("indicator %s got value for %s out of order", i.Name, v.Date())
+	}
+	i.lastTS = v.TS
+	i.updated = true
+
+	outputs, ready := i.stream.step(v.V)
+	if !ready {
+		if len(i.Series()) == 0 {
+			i.WarmUp++
+		}
+		return nil
+	}
+
+	for n, o := range outputs {
+		i.Outputs[n].Values = append(i.Outputs[n].Values, timeseries.ValueAt{TS: v.TS, V: o})
+	}
+	i.ByTimestamp[v.TS] = outputs[0]
+	i.ByDateString[v.Date()] = outputs[0]
+
+	return nil
+}
+
+type streamSnapshot struct {
+	Name    string          `json:"name"`
+	LastTS  int64           `json:"last_ts"`
+	Updated bool            `json:"updated"`
+	WarmUp  int             `json:"warm_up"`
+	State   json.RawMessage `json:"state"`
+}
+
+// Snapshot returns the internal state of an indicator created by one of
+// the New*Stream functions, e.g. the previous EMA, so that a new instance
+// can continue where this one left off using Restore. Outputs are not
+// included.
+func (i *Indicator) Snapshot() ([]byte, error) {
+	if i.stream == nil {
+		return nil, errors.Errorf("indicator %s does not support snapshots", i.Name)
+	}
+
+	state, err := json.Marshal(i.stream)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not marshal state of indicator %s", i.Name)
+	}
+
+	b, err := json.Marshal(streamSnapshot{i.Name, i.lastTS, i.updated, i.WarmUp, state})
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not marshal snapshot of indicator %s", i.Name)
+	}
+	return b, nil
+}
+
+// Restore restores state returned by Snapshot. The snapshot must be of the
+// same kind of indicator with the same parameters.
+func (i *Indicator) Restore(snapshot []byte) error {
+	if i.stream == nil {
+		return errors.Errorf("indicator %s does not support snapshots", i.Name)
+	}
+
+	var s streamSnapshot
+	err := json.Unmarshal(snapshot, &s)
+	if err != nil {
+		return errors.Wrapf(err, "could not unmarshal snapshot for indicator %s", i.Name)
+	}
+	if s.Name != i.Name {
+		return errors.Errorf("cannot restore snapshot of indicator %s into %s", s.Name, i.Name)
+	}
+
+	err = json.Unmarshal(s.State, i.stream)
+	if err != nil {
+		return errors.Wrapf(err, "could not unmarshal state of indicator %s", i.Name)
+	}
+
+	i.lastTS = s.LastTS
+	i.updated = s.Updated
+	i.WarmUp = s.WarmUp
+
+	return nil
+}
+
+// emaStepper mirrors emaSeries.
+type emaStepper struct {
+	Days  int     `json:"days"`
+	Count int     `json:"count"`
+	Total float64 `json:"total"`
+	Prev  float64 `json:"prev"`
+}
+
+func (s *emaStepper) step(v float64) ([]float64, bool) {
+	if s.Count < s.Days {
+		s.Total += v
+		s.Count++
+		if s.Count == s.Days {
+			s.Prev = s.Total / float64(s.Days)
+		}
+		return nil, false
+	}
+
+	multiplier := 2.0 / (float64(s.Days) + 1.0)
+	s.Prev = (v * multiplier) + (s.Prev * (1 - multiplier))
+
+	return []float64{s.Prev}, true
+}
+
+// smaStepper mirrors smaSeries.
+type smaStepper struct {
+	Days   int       `json:"days"`
+	Window []float64 `json:"window"`
+	Total  float64   `json:"total"`
+}
+
+func (s *smaStepper) step(v float64) ([]float64, bool) {
+	s.Total += v
+	s.Window = append(s.Window, v)
+	if len(s.Window) > s.Days {
+		s.Total -= s.Window[0]
+		s.Window = s.Window[1:]
+	}
+	if len(s.Window) < s.Days {
+		return nil, false
+	}
+	return []float64{s.Total / float64(s.Days)}, true
+}
+
+// rsiStepper mirrors rsiSeries.
+type rsiStepper struct {
+	Days    int     `json:"days"`
+	Count   int     `json:"count"`
+	Last    float64 `json:"last"`
+	AvgGain float64 `json:"avg_gain"`
+	AvgLoss float64 `json:"avg_loss"`
+}
+
+func (s *rsiStepper) step(v float64) ([]float64, bool) {
+	s.Count++
+	change := v - s.Last
+	s.Last = v
+	if s.Count == 1 {
+		return nil, false
+	}
+
+	n := float64(s.Days)
+	gain, loss := math.Max(change, 0), math.Max(-change, 0)
+
+	if s.Count-1 <= s.Days {
+		s.AvgGain += gain / n
+		s.AvgLoss += loss / n
+		if s.Count-1 < s.Days {
+			return nil, false
+		}
+	} else {
+		s.AvgGain = (s.AvgGain*(n-1) + gain) / n
+		s.AvgLoss = (s.AvgLoss*(n-1) + loss) / n
+	}
+
+	var rsi float64
+	switch {
+	case s.AvgGain == 0 && s.AvgLoss == 0:
+		rsi = 50 // Flat prices.
+	case s.AvgLoss == 0:
+		rsi = 100
+	default:
+		rsi = 100 - 100/(1+s.AvgGain/s.AvgLoss)
+	}
+
+	return []float64{rsi}, true
+}
+
+// macdStepper mirrors NewMACDIndicator.
+type macdStepper struct {
+	Fast   emaStepper `json:"fast"`
+	Slow   emaStepper `json:"slow"`
+	Signal emaStepper `json:"signal"`
+}
+
+func (s *macdStepper) step(v float64) ([]float64, bool) {
+	fast, fastReady := s.Fast.step(v)
+	slow, slowReady := s.Slow.step(v)
+	if !fastReady || !slowReady {
+		return nil, false
+	}
+
+	line := fast[0] - slow[0]
+	sig, ready := s.Signal.step(line)
+	if !ready {
+		return nil, false
+	}
+
+	return []float64{line, sig[0], line - sig[0]}, true
+}
@@ -0,0 +1,128 @@
+package trade
+
+import (
+	"math"
+	"testing"
+
+	"github.com/anrid/traderbot/pkg/timeseries"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamMatchesBatch(t *testing.T) {
+	r := require.New(t)
+
+	var vs []float64
+	for n := 0; n < 120; n++ {
+		vs = append(vs, 100+float64(n)/4+10*math.Sin(float64(n)/5)+3*math.Cos(float64(n)*1.7))
+	}
+	prices, _ := dailyPrices(vs...)
+
+	ema, err := NewEMAStream(9)
+	r.NoError(err)
+	sma, err := NewSMAStream(20)
+	r.NoError(err)
+	rsi, err := NewRSIStream(14)
+	r.NoError(err)
+	macd, err := NewMACDStream(12, 26, 9)
+	r.NoError(err)
+
+	batchSMA, err := NewSMAIndicator(20, prices)
+	r.NoError(err)
+	batchRSI, err := NewRSIIndicator(14, prices)
+	r.NoError(err)
+	batchMACD, err := NewMACDIndicator(12, 26, 9, prices)
+	r.NoError(err)
+
+	cases := []struct {
+		stream *Indicator
+		batch  *Indicator
+	}{
+		{ema, NewEMAIndicator(9, prices)},
+		{sma, batchSMA},
+		{rsi, batchRSI},
+		{macd, batchMACD},
+	}
+
+	for _, c := range cases {
+		// Update the first half, then continue from a snapshot.
+		half := len(prices) / 2
+		for _, p := range prices[:half] {
+			r.NoError(c.stream.Update(p))
+		}
+
+		snapshot, err := c.stream.Snapshot()
+		r.NoError(err)
+
+		restored, err := newStreamLike(c.stream)
+		r.NoError(err)
+		r.NoError(restored.Restore(snapshot))
+
+		for _, p := range prices[half:] {
+			r.NoError(c.stream.Update(p))
+			r.NoError(restored.Update(p))
+		}
+
+		r.Equal(c.batch.Name, c.stream.Name)
+		r.Equal(c.batch.WarmUp, c.stream.WarmUp, c.batch.Name)
+		r.Equal(c.batch.OutputNames(), c.stream.OutputNames())
+		r.Equal(c.batch.ByTimestamp, c.stream.ByTimestamp)
+
+		for n, o := range c.batch.Outputs {
+			// Exactly the same values, not only close.
+			r.Equal(o.Values, c.stream.Outputs[n].Values, c.batch.Name)
+			r.Equal(o.Values[len(o.Values)-half:], restored.Outputs[n].Values, c.batch.Name)
+		}
+	}
+}
+
+func newStreamLike(i *Indicator) (*Indicator, error) {
+	switch s := i.stream.(type) {
+	case *emaStepper:
+		return NewEMAStream(s.Days)
+	case *smaStepper:
+		return NewSMAStream(s.Days)
+	case *rsiStepper:
+		return NewRSIStream(s.Days)
+	case *macdStepper:
+		return NewMACDStream(s.Fast.Days, s.Slow.Days, s.Signal.Days)
+	}
+	return nil, nil
+}
+
+func TestStreamErrors(t *testing.T) {
+	r := require.New(t)
+
+	prices, _ := dailyPrices(1, 2, 3)
+
+	ema, err := NewEMAStream(2)
+	r.NoError(err)
+	r.NoError(ema.Update(prices[1]))
+	r.EqualError(ema.Update(prices[0]), "indicator 2-Day EMA got value for "+prices[0].Date()+" out of order")
+	r.Error(ema.Update(prices[1]))
+	r.NoError(ema.Update(prices[2]))
+
+	snapshot, err := ema.Snapshot()
+	r.NoError(err)
+
+	other, err := NewEMAStream(3)
+	r.NoError(err)
+	r.EqualError(other.Restore(snapshot), "cannot restore snapshot of indicator 2-Day EMA into 3-Day EMA")
+
+	// Restored streams remember the last timestamp.
+	restored, err := NewEMAStream(2)
+	r.NoError(err)
+	r.NoError(restored.Restore(snapshot))
+	r.Error(restored.Update(prices[2]))
+	r.NoError(restored.Update(timeseries.ValueAt{TS: prices[2].TS + 1, V: 4}))
+
+	batch, err := NewSMAIndicator(2, prices)
+	r.NoError(err)
+	r.EqualError(batch.Update(prices[2]), "indicator 2-Day SMA does not support updates")
+	_, err = batch.Snapshot()
+	r.Error(err)
+
+	_, err = NewEMAStream(0)
+	r.Error(err)
+	_, err = NewMACDStream(26, 12, 9)
+	r.Error(err)
+}
@@ -0,0 +1,305 @@
+package trade
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/anrid/traderbot/pkg/timeseries"
+	"github.com/pkg/errors"
+)
+
+// NewSuperTrendIndicator returns SuperTrend, a trailing stop line a
+// multiple of the ATR below the price in an uptrend and above it in a
+// downtrend, commonly using the 10-day ATR and a multiplier of 3. Its
+// outputs are:
+//
+// value     = the SuperTrend line
+// direction = 1 in an uptrend, -1 in a downtrend
+//
+// The bands are centered on (high + low) / 2 and only move towards the
+// price until it closes beyond them, which flips the direction. The first
+// value starts in a downtrend unless the price closes above the upper band.
+// See NewATRIndicator on using candles approximated from closing prices.
+func NewSuperTrendIndicator(atrDays int, multiplier float64, candles timeseries.Candles) (*Indicator, error) {
+	if multiplier <= 0 {
+		return nil, errors.Errorf("invalid SuperTrend multiplier %f, must be positive", multiplier)
+	}
+
+	atr, err := atrSeries(atrDays, candles)
+	if err != nil {
+		return nil, err
+	}
+
+	var value, direction timeseries.Series
+	var upper, lower, prevClose float64
+	var up bool
+
+	for n, a := range atr {
+		c := candles[n+atrDays]
+
+		hl2 := (c.High + c.Low) / 2
+		basicUpper := hl2 + multiplier*a.V
+		basicLower := hl2 - multiplier*a.V
+
+		if n == 0 {
+			upper, lower = basicUpper, basicLower
+			up = c.Close > upper
+		} else {
+			if basicUpper < upper || prevClose > upper {
+				upper = basicUpper
+			}
+			if basicLower > lower || prevClose < lower {
+				lower = basicLower
+			}
+
+			if up && c.Close < lower {
+				up = false
+			} else if !up && c.Close > upper {
+				up = true
+			}
+		}
+
+		st, dir := upper, -1.0
+		if up {
+			st, dir = lower, 1.0
+		}
+		value = append(value, timeseries.ValueAt{TS: c.TS, V: st})
+		direction = append(direction, timeseries.ValueAt{TS: c.TS, V: dir})
+
+		prevClose = c.Close
+	}
+
+	return newMultiIndicator(fmt.Sprintf("SuperTrend(%d,%g)", atrDays, multiplier),
+		Output{ValueOutput, value},
+		Output{"direction", direction},
+	).withWarmUp(candles.Closes()), nil
+}
+
+// NewParabolicSARIndicator returns Wilder's Parabolic Stop and Reverse,
+// commonly with a step of 0.02 and a maximum of 0.2. Its outputs are:
+//
+// value     = the SAR
+// direction = 1 in an uptrend, -1 in a downtrend
+//
+// Each day the SAR moves an acceleration factor (AF) of the distance
+// towards the extreme price of the trend, never beyond the previous two
+// days' range. The AF starts at step and grows by step (up to max) every
+// time a new extreme is made. The trend reverses when the price crosses
+// the SAR, which then restarts at the extreme price. The initial trend is
+// given by the first two closes.
+func NewParabolicSARIndicator(step, max float64, candles timeseries.Candles) (*Indicator, error) {
+	if step <= 0 || max < step {
+		return nil, errors.Errorf("invalid Parabolic SAR step %f and max %f", step, max)
+	}
+	if len(candles) < 2 {
+		return nil, errors.Errorf("not enough price data (%d entries) for Parabolic SAR, need 2 entries", len(candles))
+	}
+
+	up := candles[1].Close >= candles[0].Close
+
+	var sar, ep float64
+	if up {
+		sar, ep = math.Min(candles[0].Low, candles[1].Low), math.Max(candles[0].High, candles[1].High)
+	} else {
+		sar, ep = math.Max(candles[0].High, candles[1].High), math.Min(candles[0].Low, candles[1].Low)
+	}
+	af := step
+
+	dir := func() float64 {
+		if up {
+			return 1
+		}
+		return -1
+	}
+
+	value := timeseries.Series{{TS: candles[1].TS, V: sar}}
+	direction := timeseries.Series{{TS: candles[1].TS, V: dir()}}
+
+	for i := 2; i < len(candles); i++ {
+		c, prev1, prev2 := candles[i], candles[i-1], candles[i-2]
+
+		sar = sar + af*(ep-sar)
+
+		if up {
+			sar = math.Min(sar, math.Min(prev1.Low, prev2.Low))
+			if c.Low < sar {
+				up = false
+				sar, ep, af = ep, c.Low, step
+			} else if c.High > ep {
+				ep, af = c.High, math.Min(af+step, max)
+			}
+		} else {
+			sar = math.Max(sar, math.Max(prev1.High, prev2.High))
+			if c.High > sar {
+				up = true
+				sar, ep, af = ep, c.High, step
+			} else if c.Low < ep {
+				ep, af = c.Low, math.Min(af+step, max)
+			}
+		}
+
+		value = append(value, timeseries.ValueAt{TS: c.TS, V: sar})
+		direction = append(direction, timeseries.ValueAt{TS: c.TS, V: dir()})
+	}
+
+	return newMultiIndicator(fmt.Sprintf("PSAR(%g,%g)", step, max),
+		Output{ValueOutput, value},
+		Output{"direction", direction},
+	).withWarmUp(candles.Closes()), nil
+}
+
+// NewADXIndicator returns Wilder's Average Directional Index and the
+// Directional Movement Index, commonly over 14 days. Its outputs are:
+//
+// adx      = Wilder's average of DX = |+DI - -DI| / (+DI + -DI) x 100
+// plus_di  = smoothed +DM / smoothed true range x 100
+// minus_di = smoothed -DM / smoothed true range x 100
+//
+// where +DM is today's high minus yesterday's high and -DM yesterday's low
+// minus today's low, whichever is larger (and positive), the other being
+// 0. The ADX needs 2N days of data and all outputs start on its first day.
+// See NewATRIndicator on using candles approximated from closing prices.
+func NewADXIndicator(days int, candles timeseries.Candles) (*Indicator, error) {
+	if days <= 0 || len(candles) < 2*days {
+		return nil, errors.Errorf("not enough price data (%d entries) for ADX observation period (%d days), need %d entries", len(candles), days, 2*days)
+	}
+
+	n := float64(days)
+
+	var tr, plusDM, minusDM, adx float64
+	var dxs int
+	var adxs, plusDIs, minusDIs timeseries.Series
+
+	for i := 1; i < len(candles); i++ {
+		c, prev := candles[i], candles[i-1]
+
+		curTR := math.Max(c.High-c.Low, math.Max(math.Abs(c.High-prev.Close), math.Abs(c.Low-prev.Close)))
+
+		upMove, downMove := c.High-prev.High, prev.Low-c.Low
+		var curPlusDM, curMinusDM float64
+		if upMove > downMove && upMove > 0 {
+			curPlusDM = upMove
+		}
+		if downMove > upMove && downMove > 0 {
+			curMinusDM = downMove
+		}
+
+		if i <= days {
+			// Wilder's smoothing starts from the sum of the first N days.
+			tr += curTR
+			plusDM += curPlusDM
+			minusDM += curMinusDM
+			if i < days {
+				continue
+			}
+		} else {
+			tr = tr - tr/n + curTR
+			plusDM = plusDM - plusDM/n + curPlusDM
+			minusDM = minusDM - minusDM/n + curMinusDM
+		}
+
+		var plusDI, minusDI, dx float64
+		if tr > 0 {
+			plusDI = plusDM / tr * 100
+			minusDI = minusDM / tr * 100
+		}
+		if plusDI+minusDI > 0 {
+			dx = math.Abs(plusDI-minusDI) / (plusDI + minusDI) * 100
+		}
+
+		dxs++
+		if dxs <= days {
+			adx += dx / n
+			if dxs < days {
+				continue
+			}
+		} else {
+			adx = (adx*(n-1) + dx) / n
+		}
+
+		adxs = append(adxs, timeseries.ValueAt{TS: c.TS, V: adx})
+		plusDIs = append(plusDIs, timeseries.ValueAt{TS: c.TS, V: plusDI})
+		minusDIs = append(minusDIs, timeseries.ValueAt{TS: c.TS, V: minusDI})
+	}
+
+	return newMultiIndicator(fmt.Sprintf("ADX(%d)", days),
+		Output{"adx", adxs},
+		Output{"plus_di", plusDIs},
+		Output{"minus_di", minusDIs},
+	).withWarmUp(candles.Closes()), nil
+}
+
+// NewIchimokuIndicator returns the Ichimoku Cloud, commonly using 9, 26
+// and 52 days. Its outputs are:
+//
+// tenkan   = (highest high + lowest low) / 2 over tenkan days
+// kijun    = (highest high + lowest low) / 2 over kijun days
+// senkou_a = (tenkan + kijun) / 2, shifted kijun days forward
+// senkou_b = (highest high + lowest low) / 2 over senkouB days, shifted
+//            kijun days forward
+// chikou   = the close, shifted kijun days back
+//
+// The senkou spans (the cloud) extend kijun days beyond the last candle,
+// at timestamps extrapolated from the interval between the last two
+// candles. The chikou span ends kijun days before the last candle.
+func NewIchimokuIndicator(tenkan, kijun, senkouB int, candles timeseries.Candles) (*Indicator, error) {
+	if tenkan <= 0 || kijun <= 0 || senkouB <= 0 {
+		return nil, errors.Errorf("invalid Ichimoku periods (%d, %d, %d)", tenkan, kijun, senkouB)
+	}
+	longest := int(math.Max(float64(tenkan), math.Max(float64(kijun), float64(senkouB))))
+	if len(candles) < longest || len(candles) < 2 {
+		return nil, errors.Errorf("not enough price data (%d entries) for Ichimoku periods (%d, %d, %d), need %d entries",
+			len(candles), tenkan, kijun, senkouB, longest)
+	}
+
+	// Timestamp of the candle at index i, extrapolated beyond the last one.
+	last := len(candles) - 1
+	interval := candles[last].TS - candles[last-1].TS
+	tsAt := func(i int) int64 {
+		if i <= last {
+			return candles[i].TS
+		}
+		return candles[last].TS + int64(i-last)*interval
+	}
+
+	midpoint := func(days, i int) (float64, bool) {
+		if i < days-1 {
+			return 0, false
+		}
+		lowest, highest := math.Inf(1), math.Inf(-1)
+		for _, c := range candles[i-days+1 : i+1] {
+			lowest = math.Min(lowest, c.Low)
+			highest = math.Max(highest, c.High)
+		}
+		return (highest + lowest) / 2, true
+	}
+
+	var tenkans, kijuns, senkouAs, senkouBs, chikous timeseries.Series
+	for i, c := range candles {
+		t, tFound := midpoint(tenkan, i)
+		if tFound {
+			tenkans = append(tenkans, timeseries.ValueAt{TS: c.TS, V: t})
+		}
+		k, kFound := midpoint(kijun, i)
+		if kFound {
+			kijuns = append(kijuns, timeseries.ValueAt{TS: c.TS, V: k})
+		}
+		if tFound && kFound {
+			senkouAs = append(senkouAs, timeseries.ValueAt{TS: tsAt(i + kijun), V: (t + k) / 2})
+		}
+		if b, found := midpoint(senkouB, i); found {
+			senkouBs = append(senkouBs, timeseries.ValueAt{TS: tsAt(i + kijun), V: b})
+		}
+		if i >= kijun {
+			chikous = append(chikous, timeseries.ValueAt{TS: candles[i-kijun].TS, V: c.Close})
+		}
+	}
+
+	return newMultiIndicator(fmt.Sprintf("Ichimoku(%d,%d,%d)", tenkan, kijun, senkouB),
+		Output{"tenkan", tenkans},
+		Output{"kijun", kijuns},
+		Output{"senkou_a", senkouAs},
+		Output{"senkou_b", senkouBs},
+		Output{"chikou", chikous},
+	).withWarmUp(candles.Closes()), nil
+}
@@ -0,0 +1,160 @@
+package trade
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anrid/traderbot/pkg/timeseries"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuperTrendIndicator(t *testing.T) {
+	r := require.New(t)
+
+	cs := testCandles()
+
+	// ATR(2) = [_, _, 2.0, 2.25, 2.875]
+	//
+	// Day 3: bands 11.5 ± 1.0, closes below the upper band: downtrend.
+	// Day 4: bands 10.25 ± 1.125, the upper band moves down to 11.375.
+	// Day 5: bands 12.5 ± 1.4375, closes above the upper band of 11.375:
+	//        uptrend at the lower band of 11.0625.
+	st, err := NewSuperTrendIndicator(2, 0.5, cs)
+	r.NoError(err)
+	r.Equal("SuperTrend(2,0.5)", st.Name)
+	r.Equal(2, st.WarmUp)
+
+	dir := output(r, st, "direction")
+
+	r.InDelta(12.5, st.ForTimestamp(cs[2].TS), 1e-9)
+	r.Equal(-1.0, dir.ForTimestamp(cs[2].TS))
+	r.InDelta(11.375, st.ForTimestamp(cs[3].TS), 1e-9)
+	r.Equal(-1.0, dir.ForTimestamp(cs[3].TS))
+	r.InDelta(11.0625, st.ForTimestamp(cs[4].TS), 1e-9)
+	r.Equal(1.0, dir.ForTimestamp(cs[4].TS))
+
+	_, err = NewSuperTrendIndicator(5, 3, cs)
+	r.Error(err)
+}
+
+func TestParabolicSARIndicator(t *testing.T) {
+	r := require.New(t)
+
+	_, days := dailyPrices(0, 0, 0, 0, 0, 0)
+	cs := timeseries.Candles{
+		{TS: days[0], High: 10, Low: 9, Close: 9.5},
+		{TS: days[1], High: 11, Low: 10, Close: 10.5}, // Uptrend, SAR = 9, EP = 11
+		{TS: days[2], High: 12, Low: 11, Close: 11.5}, // SAR = 9.04, capped at the low of 9, EP = 12, AF = 0.04
+		{TS: days[3], High: 13, Low: 12, Close: 12.5}, // SAR = 9 + 0.04 x (12 - 9), EP = 13, AF = 0.06
+		{TS: days[4], High: 12.5, Low: 8.5, Close: 9}, // SAR = 9.3528 > low: reverse at EP = 13
+		{TS: days[5], High: 10, Low: 8, Close: 8.2},   // SAR = 12.91, capped at the high of 13
+	}
+
+	sar, err := NewParabolicSARIndicator(0.02, 0.2, cs)
+	r.NoError(err)
+	r.Equal(1, sar.WarmUp)
+
+	r.Equal(days[1:], timestamps(sar.Series()))
+	r.Equal([]float64{9, 9, 9.12, 13, 13}, values(roundSeries(sar.Series())))
+
+	r.Equal([]float64{1, 1, 1, -1, -1}, values(output(r, sar, "direction").Series()))
+
+	_, err = NewParabolicSARIndicator(0.02, 0.01, cs)
+	r.Error(err)
+	_, err = NewParabolicSARIndicator(0.02, 0.2, cs[:1])
+	r.Error(err)
+}
+
+func TestADXIndicator(t *testing.T) {
+	r := require.New(t)
+
+	cs := testCandles()
+
+	// Day  TR   +DM  -DM
+	//   2  2    1    0
+	//   3  2    1    0    +DI = 2/4 = 50%, -DI = 0%, DX = 100
+	//   4  2.5  0    2    +DI = 1/4.5, -DI = 2/4.5, DX = 33.33, ADX = 66.67
+	//   5  3.5  1.5  0    +DI = 2/5.75, -DI = 1/5.75, DX = 33.33, ADX = 50
+	adx, err := NewADXIndicator(2, cs)
+	r.NoError(err)
+	r.Equal(3, adx.WarmUp)
+
+	plusDI, minusDI := output(r, adx, "plus_di"), output(r, adx, "minus_di")
+
+	r.Equal(0.0, adx.ForTimestamp(cs[2].TS))
+	r.InDelta(200.0/3, adx.ForTimestamp(cs[3].TS), 1e-9)
+	r.InDelta(100/4.5, plusDI.ForTimestamp(cs[3].TS), 1e-9)
+	r.InDelta(200/4.5, minusDI.ForTimestamp(cs[3].TS), 1e-9)
+	r.InDelta(50.0, adx.ForTimestamp(cs[4].TS), 1e-9)
+	r.InDelta(200/5.75, plusDI.ForTimestamp(cs[4].TS), 1e-9)
+	r.InDelta(100/5.75, minusDI.ForTimestamp(cs[4].TS), 1e-9)
+
+	_, err = NewADXIndicator(3, cs)
+	r.Error(err)
+}
+
+func TestIchimokuIndicator(t *testing.T) {
+	r := require.New(t)
+
+	// Highs are i + 2, lows i and closes i + 1, so the midpoint over N
+	// days is i + 1.5 - N/2.
+	_, days := dailyPrices(0, 0, 0, 0, 0, 0)
+	var cs timeseries.Candles
+	for i, ts := range days {
+		cs = append(cs, timeseries.Candle{TS: ts, High: float64(i + 2), Low: float64(i), Close: float64(i + 1)})
+	}
+	future := func(n int) int64 {
+		return days[5] + int64(n)*(24*time.Hour).Milliseconds()
+	}
+
+	ich, err := NewIchimokuIndicator(2, 3, 4, cs)
+	r.NoError(err)
+	r.Equal([]string{"tenkan", "kijun", "senkou_a", "senkou_b", "chikou"}, ich.OutputNames())
+	r.Equal(1, ich.WarmUp)
+
+	tenkan := output(r, ich, "tenkan").Series()
+	r.Equal(days[1:], timestamps(tenkan))
+	r.Equal([]float64{1.5, 2.5, 3.5, 4.5, 5.5}, values(tenkan))
+
+	kijun := output(r, ich, "kijun").Series()
+	r.Equal(days[2:], timestamps(kijun))
+	r.Equal([]float64{2, 3, 4, 5}, values(kijun))
+
+	// Spans are shifted 3 days forward, beyond the last candle.
+	senkouA := output(r, ich, "senkou_a").Series()
+	r.Equal([]int64{days[5], future(1), future(2), future(3)}, timestamps(senkouA))
+	r.Equal([]float64{2.25, 3.25, 4.25, 5.25}, values(senkouA))
+
+	senkouB := output(r, ich, "senkou_b").Series()
+	r.Equal([]int64{future(1), future(2), future(3)}, timestamps(senkouB))
+	r.Equal([]float64{2.5, 3.5, 4.5}, values(senkouB))
+
+	// Chikou is shifted 3 days back.
+	chikou := output(r, ich, "chikou").Series()
+	r.Equal(days[:3], timestamps(chikou))
+	r.Equal([]float64{4, 5, 6}, values(chikou))
+
+	_, err = NewIchimokuIndicator(2, 3, 7, cs)
+	r.Error(err)
+}
+
+func roundSeries(s timeseries.Series) (out timeseries.Series) {
+	for _, v := range s {
+		out = append(out, timeseries.ValueAt{TS: v.TS, V: float64(int64(v.V*1e9+0.5)) / 1e9})
+	}
+	return
+}
+
+func values(s timeseries.Series) (vs []float64) {
+	for _, v := range s {
+		vs = append(vs, v.V)
+	}
+	return
+}
+
+func timestamps(s timeseries.Series) (ts []int64) {
+	for _, v := range s {
+		ts = append(ts, v.TS)
+	}
+	return
+}
@@ -0,0 +1,150 @@
+package trade
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/anrid/traderbot/pkg/timeseries"
+	"github.com/pkg/errors"
+)
+
+// NewBollingerIndicator returns Bollinger Bands for the given period and
+// width, commonly 20 days and 2 standard deviations. Its outputs are bands
+// K standard deviations above and below an SMA of the price:
+//
+// middle    = SMA(N)
+// upper     = middle + K x standard deviation over N days
+// lower     = middle - K x standard deviation over N days
+// percent_b = (price - lower) / (upper - lower)
+// bandwidth = (upper - lower) / middle
+//
+// percent_b is 0.5 when the bands have no width.
+func NewBollingerIndicator(days int, k float64, prices timeseries.Series) (*Indicator, error) {
+	if k <= 0 {
+		return nil, errors.Errorf("invalid Bollinger Bands width %f, must be positive", k)
+	}
+
+	middle, err := smaSeries(days, prices)
+	if err != nil {
+		return nil, err
+	}
+
+	var upper, lower, percentB, bandwidth timeseries.Series
+	for j, m := range middle {
+		i := j + days - 1
+		cur := prices[i]
+
+		var variance float64
+		for _, p := range prices[i-days+1 : i+1] {
+			variance += (p.V - m.V) * (p.V - m.V)
+		}
+		sd := math.Sqrt(variance / float64(days))
+
+		u := m.V + k*sd
+		l := m.V - k*sd
+
+		upper = append(upper, timeseries.ValueAt{TS: cur.TS, V: u})
+		lower = append(lower, timeseries.ValueAt{TS: cur.TS, V: l})
+
+		b := 0.5
+		if u > l {
+			b = (cur.V - l) / (u - l)
+		}
+		percentB = append(percentB, timeseries.ValueAt{TS: cur.TS, V: b})
+
+		if m.V != 0 {
+			bandwidth = append(bandwidth, timeseries.ValueAt{TS: cur.TS, V: (u - l) / m.V})
+		}
+	}
+
+	return newMultiIndicator(fmt.Sprintf("BB(%d,%g)", days, k),
+		Output{"middle", middle},
+		Output{"upper", upper},
+		Output{"lower", lower},
+		Output{"percent_b", percentB},
+		Output{"bandwidth", bandwidth},
+	).withWarmUp(prices), nil
+}
+
+// NewATRIndicator returns Wilder's Average True Range, where the true
+// range of a day is the largest of:
+//
+// - high - low
+// - |high - previous close|
+// - |low - previous close|
+//
+// The first ATR is the mean true range of the first N days (after the
+// first candle), after which it's smoothed: ATR = (prev ATR x (N-1) + TR) / N.
+//
+// With candles approximated from closing prices (see
+// timeseries.CandlesFromSeries) the true range is the close-to-close
+// change, which understates the range on days that reverse intraday.
+func NewATRIndicator(days int, candles timeseries.Candles) (*Indicator, error) {
+	atr, err := atrSeries(days, candles)
+	if err != nil {
+		return nil, err
+	}
+	return newIndicator(fmt.Sprintf("%d-Day ATR", days), atr).withWarmUp(candles.Closes()), nil
+}
+
+func atrSeries(days int, candles timeseries.Candles) (timeseries.Series, error) {
+	if days <= 0 || len(candles) < days+1 {
+		return nil, errors.Errorf("not enough price data (%d entries) for ATR observation period (%d days), need %d entries", len(candles), days, days+1)
+	}
+
+	n := float64(days)
+
+	var atr float64
+	var out timeseries.Series
+	for i := 1; i < len(candles); i++ {
+		c, prevClose := candles[i], candles[i-1].Close
+		tr := math.Max(c.High-c.Low, math.Max(math.Abs(c.High-prevClose), math.Abs(c.Low-prevClose)))
+
+		if i <= days {
+			atr += tr / n
+			if i < days {
+				continue
+			}
+		} else {
+			atr = (atr*(n-1) + tr) / n
+		}
+
+		out = append(out, timeseries.ValueAt{TS: c.TS, V: atr})
+	}
+
+	return out, nil
+}
+
+// NewKeltnerIndicator returns Keltner Channels, commonly using a 20-day
+// EMA and 2 x the 10-day ATR. Its outputs are bands a multiple of the ATR
+// above and below an EMA of the closing price:
+//
+// middle = EMA(N)
+// upper  = middle + multiplier x ATR
+// lower  = middle - multiplier x ATR
+//
+// See NewATRIndicator on using candles approximated from closing prices.
+func NewKeltnerIndicator(emaDays, atrDays int, multiplier float64, candles timeseries.Candles) (*Indicator, error) {
+	if multiplier <= 0 {
+		return nil, errors.Errorf("invalid Keltner Channels multiplier %f, must be positive", multiplier)
+	}
+
+	middle, err := emaSeries(emaDays, candles.Closes())
+	if err != nil {
+		return nil, err
+	}
+	atr, err := atrSeries(atrDays, candles)
+	if err != nil {
+		return nil, err
+	}
+
+	upper := combineSeries(func(vs ...float64) float64 { return vs[0] + multiplier*vs[1] }, middle, atr)
+	lower := combineSeries(func(vs ...float64) float64 { return vs[0] - multiplier*vs[1] }, middle, atr)
+	middle = combineSeries(func(vs ...float64) float64 { return vs[0] }, middle, atr)
+
+	return newMultiIndicator(fmt.Sprintf("KC(%d,%d,%g)", emaDays, atrDays, multiplier),
+		Output{"middle", middle},
+		Output{"upper", upper},
+		Output{"lower", lower},
+	).withWarmUp(candles.Closes()), nil
+}
@@ -0,0 +1,96 @@
+package trade
+
+import (
+	"math"
+	"testing"
+
+	"github.com/anrid/traderbot/pkg/timeseries"
+	"github.com/stretchr/testify/require"
+)
+
+func testCandles() timeseries.Candles {
+	_, days := dailyPrices(0, 0, 0, 0, 0)
+	return timeseries.Candles{
+		{TS: days[0], Open: 8.5, High: 10, Low: 8, Close: 9},
+		{TS: days[1], Open: 9, High: 11, Low: 9, Close: 10},       // TR = 2
+		{TS: days[2], Open: 11, High: 12, Low: 11, Close: 11.5},   // TR = |12 - 10| = 2
+		{TS: days[3], Open: 11.5, High: 11.5, Low: 9, Close: 9.5}, // TR = 2.5
+		{TS: days[4], Open: 12.5, High: 13, Low: 12, Close: 12.5}, // TR = |13 - 9.5| = 3.5
+	}
+}
+
+func TestBollingerIndicator(t *testing.T) {
+	r := require.New(t)
+
+	prices, days := dailyPrices(1, 2, 3, 4, 5, 5, 5)
+
+	bb, err := NewBollingerIndicator(3, 2, prices)
+	r.NoError(err)
+	r.Equal("BB(3,2)", bb.Name)
+
+	// Mean 2, standard deviation sqrt(2/3).
+	sd := math.Sqrt(2.0 / 3)
+	r.Equal(0.0, bb.ForTimestamp(days[1]))
+	r.InDelta(2.0, bb.ForTimestamp(days[2]), 1e-9)
+	r.InDelta(2+2*sd, output(r, bb, "upper").ForTimestamp(days[2]), 1e-9)
+	r.InDelta(2-2*sd, output(r, bb, "lower").ForTimestamp(days[2]), 1e-9)
+	r.InDelta((3-(2-2*sd))/(4*sd), output(r, bb, "percent_b").ForTimestamp(days[2]), 1e-9)
+	r.InDelta(4*sd/2, output(r, bb, "bandwidth").ForTimestamp(days[2]), 1e-9)
+
+	// Flat prices have no bands.
+	r.InDelta(5.0, output(r, bb, "upper").ForTimestamp(days[6]), 1e-9)
+	r.InDelta(5.0, output(r, bb, "lower").ForTimestamp(days[6]), 1e-9)
+	r.InDelta(0.5, output(r, bb, "percent_b").ForTimestamp(days[6]), 1e-9)
+	r.InDelta(0.0, output(r, bb, "bandwidth").ForTimestamp(days[6]), 1e-9)
+
+	_, err = NewBollingerIndicator(8, 2, prices)
+	r.Error(err)
+}
+
+func TestATRIndicator(t *testing.T) {
+	r := require.New(t)
+
+	cs := testCandles()
+
+	atr, err := NewATRIndicator(2, cs)
+	r.NoError(err)
+
+	r.Equal(0.0, atr.ForTimestamp(cs[1].TS))
+	r.InDelta(2.0, atr.ForTimestamp(cs[2].TS), 1e-9)
+	r.InDelta(2.25, atr.ForTimestamp(cs[3].TS), 1e-9)
+	r.InDelta(2.875, atr.ForTimestamp(cs[4].TS), 1e-9)
+
+	_, err = NewATRIndicator(5, cs)
+	r.Error(err)
+
+	// Close-to-close ranges: 1, 1, 1, 2
+	prices, days := dailyPrices(1, 2, 1, 2, 4)
+
+	atr, err = NewATRIndicator(2, timeseries.CandlesFromSeries(prices))
+	r.NoError(err)
+
+	r.InDelta(1.0, atr.ForTimestamp(days[2]), 1e-9)
+	r.InDelta(1.0, atr.ForTimestamp(days[3]), 1e-9)
+	r.InDelta(1.5, atr.ForTimestamp(days[4]), 1e-9)
+}
+
+func TestKeltnerIndicator(t *testing.T) {
+	r := require.New(t)
+
+	cs := testCandles()
+
+	// EMA(2) = [_, _, 10.8333, 9.9444, 11.6481]
+	// ATR(2) = [_, _, 2.0, 2.25, 2.875]
+	kc, err := NewKeltnerIndicator(2, 2, 1.5, cs)
+	r.NoError(err)
+	r.Equal("KC(2,2,1.5)", kc.Name)
+
+	r.Equal(0.0, kc.ForTimestamp(cs[1].TS))
+	r.InDelta(10.833333, kc.ForTimestamp(cs[2].TS), 1e-6)
+	r.InDelta(10.833333+1.5*2, output(r, kc, "upper").ForTimestamp(cs[2].TS), 1e-6)
+	r.InDelta(10.833333-1.5*2, output(r, kc, "lower").ForTimestamp(cs[2].TS), 1e-6)
+	r.InDelta(11.648148+1.5*2.875, output(r, kc, "upper").ForTimestamp(cs[4].TS), 1e-6)
+
+	_, err = NewKeltnerIndicator(2, 2, 0, cs)
+	r.Error(err)
+}
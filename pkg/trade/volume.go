@@ -0,0 +1,141 @@
+package trade
+
+import (
+	"fmt"
+
+	"github.com/anrid/traderbot/pkg/timeseries"
+	"github.com/pkg/errors"
+)
+
+// NewOBVIndicator returns On-Balance Volume, a running total of volume
+// that adds a day's volume when the price closes higher and subtracts it
+// when the price closes lower, e.g. using Market.Prices and
+// Market.TotalVolumes. The total starts at 0 on the first day found in
+// both series.
+func NewOBVIndicator(prices, volumes timeseries.Series) (*Indicator, error) {
+	aligned := combineSeries(func(vs ...float64) float64 { return vs[0] }, prices, volumes)
+	vols := combineSeries(func(vs ...float64) float64 { return vs[1] }, prices, volumes)
+	if len(aligned) == 0 {
+		return nil, errors.New("no volume data for prices")
+	}
+
+	var obv float64
+	out := timeseries.Series{{TS: aligned[0].TS, V: obv}}
+	for i := 1; i < len(aligned); i++ {
+		switch {
+		case aligned[i].V > aligned[i-1].V:
+			obv += vols[i].V
+		case aligned[i].V < aligned[i-1].V:
+			obv -= vols[i].V
+		}
+		out = append(out, timeseries.ValueAt{TS: aligned[i].TS, V: obv})
+	}
+
+	return newIndicator("OBV", out).withWarmUp(prices), nil
+}
+
+// NewVolumeMAIndicator returns the Simple Moving Average of volume.
+func NewVolumeMAIndicator(days int, volumes timeseries.Series) (*Indicator, error) {
+	sma, err := smaSeries(days, volumes)
+	if err != nil {
+		return nil, err
+	}
+	return newIndicator(fmt.Sprintf("%d-Day Volume SMA", days), sma).withWarmUp(volumes), nil
+}
+
+// NewVolumeSpikeIndicator returns a day's volume relative to the average
+// volume of the N days before it, e.g. 3.0 for a day trading three times
+// the usual volume. Days following N days without volume are skipped.
+func NewVolumeSpikeIndicator(days int, volumes timeseries.Series) (*Indicator, error) {
+	if days <= 0 || len(volumes) < days+1 {
+		return nil, errors.Errorf("not enough volume data (%d entries) for observation period (%d days), need %d entries", len(volumes), days, days+1)
+	}
+
+	sma, err := smaSeries(days, volumes)
+	if err != nil {
+		return nil, err
+	}
+
+	var out timeseries.Series
+	for i := days; i < len(volumes); i++ {
+		// sma[0] is the average of the first N days, i.e. the days
+		// before volumes[days].
+		avg := sma[i-days].V
+		if avg == 0 {
+			continue
+		}
+		out = append(out, timeseries.ValueAt{TS: volumes[i].TS, V: volumes[i].V / avg})
+	}
+
+	return newIndicator(fmt.Sprintf("%d-Day Volume Spike", days), out).withWarmUp(volumes), nil
+}
+
+// NewMFIIndicator returns the Money Flow Index (0 - 100), a volume
+// weighted RSI:
+//
+// Typical price = (high + low + close) / 3
+// Money flow    = typical price x volume
+// MFI           = 100 - 100 / (1 + positive flow / negative flow)
+//
+// where the flows are summed over N days, counting a day as positive if
+// its typical price is above the previous day's. See NewATRIndicator on
+// using candles approximated from closing prices.
+func NewMFIIndicator(days int, candles timeseries.Candles, volumes timeseries.Series) (*Indicator, error) {
+	vs := make(map[int64]float64, len(volumes))
+	for _, v := range volumes {
+		vs[v.TS] = v.V
+	}
+
+	var tps, flows timeseries.Series
+	for _, c := range candles {
+		v, found := vs[c.TS]
+		if !found {
+			continue
+		}
+		tp := (c.High + c.Low + c.Close) / 3
+		tps = append(tps, timeseries.ValueAt{TS: c.TS, V: tp})
+		flows = append(flows, timeseries.ValueAt{TS: c.TS, V: tp * v})
+	}
+
+	if days <= 0 || len(tps) < days+1 {
+		return nil, errors.Errorf("not enough price and volume data (%d entries) for MFI observation period (%d days), need %d entries", len(tps), days, days+1)
+	}
+
+	var out timeseries.Series
+	for i := days; i < len(tps); i++ {
+		var pos, neg float64
+		for j := i - days + 1; j <= i; j++ {
+			switch {
+			case tps[j].V > tps[j-1].V:
+				pos += flows[j].V
+			case tps[j].V < tps[j-1].V:
+				neg += flows[j].V
+			}
+		}
+
+		var mfi float64
+		switch {
+		case pos == 0 && neg == 0:
+			mfi = 50
+		case neg == 0:
+			mfi = 100
+		default:
+			mfi = 100 - 100/(1+pos/neg)
+		}
+		out = append(out, timeseries.ValueAt{TS: tps[i].TS, V: mfi})
+	}
+
+	return newIndicator(fmt.Sprintf("%d-Day MFI", days), out).withWarmUp(candles.Closes()), nil
+}
+
+// NewMarketCapMomentumIndicator returns the percentage change in market
+// cap over the observation period, e.g. using Market.MarketCaps. Unlike
+// the price ROC it includes the effect of new supply.
+func NewMarketCapMomentumIndicator(days int, marketCaps timeseries.Series) (*Indicator, error) {
+	roc, err := NewROCIndicator(days, marketCaps)
+	if err != nil {
+		return nil, err
+	}
+	roc.Name = fmt.Sprintf("%d-Day Market Cap Momentum", days)
+	return roc, nil
+}
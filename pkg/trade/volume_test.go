@@ -0,0 +1,79 @@
+package trade
+
+import (
+	"testing"
+
+	"github.com/anrid/traderbot/pkg/timeseries"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOBVIndicator(t *testing.T) {
+	r := require.New(t)
+
+	prices, days := dailyPrices(1, 2, 2, 1, 3)
+	volumes, _ := dailyPrices(10, 20, 30, 40, 50)
+
+	i, err := NewOBVIndicator(prices, volumes)
+	r.NoError(err)
+
+	r.Equal(0.0, i.ForTimestamp(days[0]))
+	r.Equal(20.0, i.ForTimestamp(days[1]))
+	r.Equal(20.0, i.ForTimestamp(days[2]))
+	r.Equal(-20.0, i.ForTimestamp(days[3]))
+	r.Equal(30.0, i.ForTimestamp(days[4]))
+
+	_, err = NewOBVIndicator(prices, nil)
+	r.Error(err)
+}
+
+func TestVolumeSpikeIndicator(t *testing.T) {
+	r := require.New(t)
+
+	volumes, days := dailyPrices(10, 20, 30, 40, 100)
+
+	ma, err := NewVolumeMAIndicator(2, volumes)
+	r.NoError(err)
+	r.Equal(70.0, ma.ForTimestamp(days[4]))
+
+	i, err := NewVolumeSpikeIndicator(2, volumes)
+	r.NoError(err)
+
+	r.Equal(0.0, i.ForTimestamp(days[1]))
+	r.InDelta(2.0, i.ForTimestamp(days[2]), 1e-9)
+	r.InDelta(1.6, i.ForTimestamp(days[3]), 1e-9)
+	r.InDelta(100.0/35, i.ForTimestamp(days[4]), 1e-9)
+
+	_, err = NewVolumeSpikeIndicator(5, volumes)
+	r.Error(err)
+}
+
+func TestMFIIndicator(t *testing.T) {
+	r := require.New(t)
+
+	// Typical prices = [1, 5/3, 4/3, 7/3], i.e. up, down, up.
+	prices, days := dailyPrices(1, 2, 1, 3)
+	volumes, _ := dailyPrices(1, 1, 1, 1)
+
+	i, err := NewMFIIndicator(2, timeseries.CandlesFromSeries(prices), volumes)
+	r.NoError(err)
+
+	r.Equal(0.0, i.ForTimestamp(days[1]))
+	r.InDelta(100-100/(1+(5.0/3)/(4.0/3)), i.ForTimestamp(days[2]), 1e-9)
+	r.InDelta(100-100/(1+(7.0/3)/(4.0/3)), i.ForTimestamp(days[3]), 1e-9)
+
+	_, err = NewMFIIndicator(2, timeseries.CandlesFromSeries(prices), volumes[:2])
+	r.Error(err)
+}
+
+func TestMarketCapMomentumIndicator(t *testing.T) {
+	r := require.New(t)
+
+	caps, days := dailyPrices(100, 110, 121, 100)
+
+	i, err := NewMarketCapMomentumIndicator(2, caps)
+	r.NoError(err)
+	r.Equal("2-Day Market Cap Momentum", i.Name)
+
+	r.InDelta(21.0, i.ForTimestamp(days[2]), 1e-9)
+	r.InDelta(-100.0/11, i.ForTimestamp(days[3]), 1e-9)
+}